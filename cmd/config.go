@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// configCommand inspects the configuration
+func configCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration as YAML, with secrets redacted",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, _ := a.load()
+			if err := cfg.Print(cmd.OutOrStdout()); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+				os.Exit(1)
+			}
+		},
+	})
+
+	return cmd
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/spf13/cobra"
+)
+
+// keysCommand manages the API keys of the services calling the API
+func keysCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage the API keys of other services",
+	}
+
+	// keys connects to the database for the subcommands. The schema must be
+	// up to date.
+	keys := func() repository.APIKeyRepository {
+		cfg, logger := a.load()
+
+		conn, err := db.Init(cfg.Database, logger)
+		if err != nil {
+			fatal(logger, "Failed to set up database", err)
+		}
+		migrations, err := db.Migrations()
+		if err != nil {
+			fatal(logger, "Failed to load migrations", err)
+		}
+		if err := db.NewMigrator(conn, migrations).Check(context.Background()); err != nil {
+			exitWith(err)
+		}
+		return repository.NewGormAPIKeyRepository(conn)
+	}
+
+	var scopes []string
+	var expires time.Duration
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create an API key and print it",
+		Long:  "Create an API key and print it. The key can't be shown again, only its hash is stored.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			parsed, err := auth.ParseScopes(scopes)
+			if err != nil {
+				exitWith(err)
+			}
+			if len(parsed) == 0 {
+				exitWith(errors.New("at least one --scope is required"))
+			}
+			if expires < 0 {
+				exitWith(errors.New("--expires must be positive"))
+			}
+
+			var expiresAt *time.Time
+			if expires > 0 {
+				at := time.Now().UTC().Add(expires)
+				expiresAt = &at
+			}
+
+			key, record := auth.NewAPIKey(args[0], parsed, expiresAt)
+			if err := keys().Create(context.Background(), &record); err != nil {
+				exitWith(err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Created key %s, store it now as it won't be shown again\n", record.ID)
+			fmt.Fprintln(cmd.OutOrStdout(), key)
+		},
+	}
+	create.Flags().StringSliceVar(&scopes, "scope", nil, "Scope of the key: orders:read, orders:write or admin (repeatable)")
+	create.Flags().DurationVar(&expires, "expires", 0, "Lifetime of the key, e.g. 2160h, it never expires when 0")
+	cmd.AddCommand(create)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the API keys",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			list, err := keys().List(context.Background())
+			if err != nil {
+				exitWith(err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED\tEXPIRES\tLAST USED\tSTATUS")
+			now := time.Now()
+			for _, k := range list {
+				status := "active"
+				switch {
+				case k.RevokedAt != nil:
+					status = "revoked " + k.RevokedAt.Format(time.RFC3339)
+				case k.ExpiresAt != nil && !now.Before(*k.ExpiresAt):
+					status = "expired"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					k.ID, k.Name, strings.ReplaceAll(k.Scopes, " ", ","),
+					k.CreatedAt.Format(time.RFC3339), formatTime(k.ExpiresAt, "never"),
+					formatTime(k.LastUsedAt, "never"), status)
+			}
+			w.Flush()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revoke an API key, it is rejected from then on",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := keys().Revoke(context.Background(), args[0], time.Now().UTC()); err != nil {
+				if errors.Is(err, repository.ErrNotFound) {
+					err = fmt.Errorf("no API key with ID %q", args[0])
+				}
+				exitWith(err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Revoked %s\n", args[0])
+		},
+	})
+
+	return cmd
+}
+
+// formatTime formats t, or returns unset when it is nil
+func formatTime(t *time.Time, unset string) string {
+	if t == nil {
+		return unset
+	}
+	return t.Format(time.RFC3339)
+}
@@ -1,96 +1,79 @@
 package main
 
 import (
-	"context"
 	"fmt"
-	"log"
-	"net/http"
-	"time"
-
-	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db"
-	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/operation"
-	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
-	"github.com/danielgtaylor/huma/v2"
-	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"log/slog"
+	"os"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
 	"github.com/danielgtaylor/huma/v2/humacli"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/metrics"
 	"github.com/joho/godotenv"
-	"gorm.io/gorm"
+	"github.com/spf13/pflag"
 )
 
-// Options for the CLI.
+// Options for the CLI. The service itself is configured by config.Config,
+// whose flags are registered alongside.
 type Options struct {
-	Port int `help:"Port to listen on" short:"p" default:"8082"`
+	Config string `doc:"Path to an optional YAML configuration file" short:"c"`
 }
 
-var (
-	dbConn *gorm.DB
-)
-
-func main() {
-	_ = godotenv.Load()
-	dbConn = db.Init()
-	conn, ch := rabbitmq.Connect()
+// app is shared by the commands. The configuration is only loaded by the
+// commands that need it.
+type app struct {
+	flags   *pflag.FlagSet
+	options *Options
+}
 
-	// Set up event handlers
-	eventRouter := rabbitmq.SetupEventHandlers(dbConn)
+// load loads and validates the configuration and sets up the default
+// logger. It exits when either fails.
+func (a *app) load() (*config.Config, *slog.Logger) {
+	cfg, err := config.Load(a.options.Config, a.flags)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
-	// Start listening for events
-	_, err := rabbitmq.StartListening(ch, eventRouter)
+	logger, err := logging.New(os.Stdout, logging.Options{Level: cfg.Log.Level, Format: cfg.Log.Format})
 	if err != nil {
-		log.Fatalf("Failed to start event listener: %v", err)
+		fmt.Fprintf(os.Stderr, "Failed to set up logging: %v\n", err)
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
 
-	// Create a CLI app which takes a port option.
+	return cfg, logger
+}
+
+// fatal logs err and exits
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	a := &app{}
+
+	// Create a CLI app. Options are parsed before any command runs, so
+	// nothing in here may connect to the outside world.
 	cli := humacli.New(func(hooks humacli.Hooks, options *Options) {
-		// Create a new router & API
-		router := chi.NewMux()
-
-		router.Use(middleware.Logger)
-		router.Use(middleware.Recoverer)
-		router.Use(middleware.Compress(5))
-
-		router.Use(metrics.Collector(metrics.CollectorOpts{
-			Host:  false,
-			Proto: true,
-			Skip: func(r *http.Request) bool {
-				return r.Method != "OPTIONS"
-			},
-		}))
-
-		router.Handle("/metrics", metrics.Handler())
-
-		configs := huma.DefaultConfig("Paye Ton Kawa - Orders", "1.0.0")
-		api := humachi.New(router, configs)
-
-		operation.RegisterOrdersRoutes(api, dbConn, ch)
-
-		// Create the HTTP server.
-		server := http.Server{
-			Addr:    fmt.Sprintf(":%d", options.Port),
-			Handler: router,
-		}
-
-		// Tell the CLI how to start your router.
-		hooks.OnStart(func() {
-			server.ListenAndServe()
-		})
-
-		// Tell the CLI how to stop your server.
-		hooks.OnStop(func() {
-			// Give the server 5 seconds to gracefully shut down, then give up.
-			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-			defer cancel()
-			server.Shutdown(ctx)
-
-			// Close the RabbitMQ connection when server shuts down
-			conn.Close()
-			ch.Close()
-		})
+		a.options = options
+
+		srv := newServer(a)
+
+		// Tell the CLI how to start your server.
+		hooks.OnStart(srv.run)
+
+		// Tell the CLI how to stop your server. It runs on SIGINT and SIGTERM.
+		hooks.OnStop(srv.shutdown)
 	})
 
+	a.flags = cli.Root().PersistentFlags()
+	config.BindFlags(a.flags)
+
+	cli.Root().AddCommand(configCommand(a), migrateCommand(a), keysCommand(a))
+
 	// Run the CLI. When passed no commands, it starts the server.
 	cli.Run()
 }
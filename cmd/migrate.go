@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// migrateCommand manages the database schema
+func migrateCommand(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage the database schema",
+	}
+
+	// migrator connects to the database for the subcommands that need it
+	migrator := func() *db.Migrator {
+		cfg, logger := a.load()
+
+		conn, err := db.Init(cfg.Database, logger)
+		if err != nil {
+			fatal(logger, "Failed to set up database", err)
+		}
+		migrations, err := db.Migrations()
+		if err != nil {
+			fatal(logger, "Failed to load migrations", err)
+		}
+		return db.NewMigrator(conn, migrations)
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "up",
+		Short: "Apply all pending migrations",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			applied, err := migrator().Up(context.Background())
+			for _, m := range applied {
+				fmt.Fprintf(cmd.OutOrStdout(), "Applied %04d_%s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				exitWith(err)
+			}
+			if len(applied) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Schema is up to date")
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "down N",
+		Short: "Revert the last N applied migrations",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				exitWith(fmt.Errorf("N must be a positive number, got %q", args[0]))
+			}
+
+			reverted, err := migrator().Down(context.Background(), n)
+			for _, m := range reverted {
+				fmt.Fprintf(cmd.OutOrStdout(), "Reverted %04d_%s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				exitWith(err)
+			}
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "List the migrations and whether they are applied",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			statuses, err := migrator().Status(context.Background())
+			if err != nil {
+				exitWith(err)
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+			for _, s := range statuses {
+				status := "pending"
+				switch {
+				case s.Unknown:
+					status = "applied " + s.AppliedAt.Format(time.RFC3339) + " (unknown to this binary)"
+				case s.AppliedAt != nil:
+					status = "applied " + s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, status)
+			}
+			w.Flush()
+		},
+	})
+
+	var dir string
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create empty up and down files for a new migration",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			paths, err := db.CreateMigration(dir, args[0])
+			if err != nil {
+				exitWith(err)
+			}
+			for _, path := range paths {
+				fmt.Fprintf(cmd.OutOrStdout(), "Created %s\n", path)
+			}
+		},
+	}
+	create.Flags().StringVar(&dir, "dir", db.MigrationsDir, "Directory of the migrations")
+	cmd.AddCommand(create)
+
+	return cmd
+}
+
+// exitWith prints err and exits
+func exitWith(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/health"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/operation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/problem"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/ratelimit"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/saga"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/telemetry"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/metrics"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+)
+
+// server is the HTTP API and event consumer of the service
+type server struct {
+	app *app
+
+	// started is closed once everything below is set up
+	started         chan struct{}
+	cfg             *config.Config
+	logger          *slog.Logger
+	shutdownTracing func(context.Context) error
+	dbConn          *gorm.DB
+	repos           repository.Repositories
+	publisher       rabbitmq.EventPublisher
+	http            *http.Server
+
+	// The verifiers of bearer tokens and API keys, nil when authentication
+	// is disabled
+	tokens auth.Verifier
+	keys   auth.Verifier
+
+	// stopSaga stops expiring stock reservations, sagaDone is closed once
+	// stopped
+	stopSaga context.CancelFunc
+	sagaDone chan struct{}
+
+	// With the memory broker only bus is set, otherwise only the others
+	bus      *rabbitmq.MemoryBus
+	conn     *amqp.Connection
+	ch       *amqp.Channel
+	consumer *rabbitmq.Consumer
+}
+
+func newServer(a *app) *server {
+	return &server{app: a, started: make(chan struct{})}
+}
+
+// run connects to the dependencies, starts consuming events and serves HTTP
+// until shutdown. It exits when the service can't start, including when
+// the database schema is behind and migrating on start is disabled.
+func (s *server) run() {
+	s.cfg, s.logger = s.app.load()
+	logger := s.logger
+	var err error
+
+	// Tracing must be set up before the instrumented DB and AMQP clients
+	s.shutdownTracing, err = telemetry.SetupTracing(context.Background(), telemetry.TracingOptions{
+		Exporter: s.cfg.Tracing.Exporter,
+		File:     s.cfg.Tracing.File,
+	})
+	if err != nil {
+		fatal(logger, "Failed to set up tracing", err)
+	}
+
+	s.dbConn, err = db.Init(s.cfg.Database, logger)
+	if err != nil {
+		fatal(logger, "Failed to set up database", err)
+	}
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		fatal(logger, "Failed to load migrations", err)
+	}
+	migrator := db.NewMigrator(s.dbConn, migrations)
+	if s.cfg.Database.MigrateOnStart {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			fatal(logger, "Failed to migrate database", err)
+		}
+		for _, m := range applied {
+			logger.Info("Applied migration", "version", m.Version, "name", m.Name)
+		}
+	} else if err := migrator.Check(context.Background()); err != nil {
+		fatal(logger, "Refusing to start", err)
+	}
+	s.repos = repository.NewGorm(s.dbConn)
+
+	// Load the JSON Schemas used to validate incoming events
+	registry, err := schema.NewRegistry()
+	if err != nil {
+		fatal(logger, "Failed to load event schemas", err)
+	}
+
+	if s.cfg.Events.Broker == config.BrokerMemory {
+		// Events stay in process, customer and product events are injected
+		// through the API
+		s.bus = rabbitmq.NewMemoryBus(s.cfg.RabbitMQ.Exchange, logger)
+		s.publisher = s.bus
+		logger.Warn("Using the in-process event bus, events are not shared with other services")
+	} else {
+		s.conn, s.ch, err = rabbitmq.Connect(s.cfg.RabbitMQ)
+		if err != nil {
+			fatal(logger, "Failed to set up RabbitMQ", err)
+		}
+		s.publisher = rabbitmq.NewAMQPPublisher(s.ch, s.cfg.RabbitMQ.Exchange)
+	}
+
+	// The stock reservation saga is driven by events and expires the
+	// reservations left unanswered, even when new orders no longer wait
+	// for one
+	reservations := saga.NewReservations(s.repos.Orders, s.publisher, logger)
+	sagaCtx, stopSaga := context.WithCancel(context.Background())
+	s.stopSaga, s.sagaDone = stopSaga, make(chan struct{})
+	go func() {
+		defer close(s.sagaDone)
+		reservations.Run(sagaCtx)
+	}()
+
+	// Set up event handlers
+	eventRouter := rabbitmq.SetupEventHandlers(s.repos, reservations, registry, s.cfg.RabbitMQ.HandlerTimeout, logger)
+
+	if s.bus != nil {
+		s.bus.Subscribe(eventRouter)
+	} else {
+		// Start listening for events
+		s.consumer, err = rabbitmq.StartListening(context.Background(), s.ch, s.cfg.RabbitMQ, eventRouter)
+		if err != nil {
+			fatal(logger, "Failed to start event listener", err)
+		}
+	}
+
+	if s.cfg.Auth.Disabled {
+		logger.Warn("Authentication is disabled, every request acts as an admin")
+	} else {
+		// Remote keys are refreshed for as long as the service runs
+		keys, err := auth.LoadJWKS(context.Background(), s.cfg.Auth)
+		if err != nil {
+			fatal(logger, "Failed to load the keys verifying tokens", err)
+		}
+		s.tokens = auth.NewJWTVerifier(keys, s.cfg.Auth)
+		s.keys = auth.NewAPIKeyVerifier(s.repos.APIKeys, logger)
+	}
+
+	s.http = &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.cfg.HTTP.Port),
+		Handler: s.routes(),
+	}
+	close(s.started)
+
+	if err := s.http.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("HTTP server stopped", "error", err)
+	}
+}
+
+// routes creates the router serving the API, the metrics and the probes
+func (s *server) routes() http.Handler {
+	router := chi.NewMux()
+
+	router.Use(telemetry.HTTPMiddleware)
+	router.Use(correlation.Middleware)
+	router.Use(logging.Middleware(s.logger))
+	router.Use(middleware.Recoverer)
+	router.Use(middleware.Compress(5))
+
+	router.Use(metrics.Collector(metrics.CollectorOpts{
+		Host:  false,
+		Proto: true,
+		Skip: func(r *http.Request) bool {
+			return r.Method == http.MethodOptions
+		},
+	}))
+
+	router.Handle("/metrics", metrics.Handler())
+
+	// Kubernetes probes
+	checker := health.NewChecker(s.cfg.HTTP.HealthTimeout)
+	checker.Add("database", health.Database(s.dbConn))
+	if s.consumer != nil {
+		checker.Add("rabbitmq", health.RabbitMQ(s.conn, s.ch))
+		checker.Add("consumer", s.consumer.Check)
+	}
+
+	router.Handle("/healthz", health.LivenessHandler())
+	router.Handle("/readyz", checker.ReadinessHandler())
+
+	configs := huma.DefaultConfig("Paye Ton Kawa - Orders", "1.0.0")
+	auth.RegisterSecuritySchemes(configs.OpenAPI)
+	problem.Install(&configs, s.logger)
+	api := humachi.New(router, configs)
+
+	// Requests are limited by IP address before authentication, then by
+	// client
+	limits := ratelimit.NewMemoryStore()
+	if s.cfg.RateLimit.Enabled {
+		api.UseMiddleware(ratelimit.PreAuth(api, limits, s.cfg.RateLimit, s.logger))
+	}
+	if s.tokens != nil {
+		api.UseMiddleware(auth.Middleware(api, s.tokens, s.keys, s.logger))
+	} else {
+		api.UseMiddleware(auth.Anonymous())
+	}
+	if s.cfg.RateLimit.Enabled {
+		api.UseMiddleware(ratelimit.Middleware(api, limits, s.cfg.RateLimit, s.logger))
+	}
+
+	productsClient := operation.NewProductsClient(s.cfg.Products)
+	operation.RegisterOrdersRoutes(api, s.repos.Orders, s.publisher, productsClient, s.cfg.Orders, s.logger)
+	if s.bus != nil {
+		operation.RegisterEventRoutes(api, s.bus)
+	}
+
+	return router
+}
+
+// shutdown drains HTTP requests and events, then closes the connections
+func (s *server) shutdown() {
+	select {
+	case <-s.started:
+	default:
+		// Interrupted while connecting, there is nothing to drain
+		slog.Warn("Interrupted during startup")
+		return
+	}
+
+	logger := s.logger
+
+	// Everything below shares a single deadline
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting HTTP requests and wait for in-flight ones
+	if err := s.http.Shutdown(ctx); err != nil {
+		logger.Warn("HTTP server shutdown", "error", err)
+	}
+
+	if s.consumer != nil {
+		// Stop consuming and wait for the in-flight event handler
+		if err := s.consumer.Shutdown(ctx); err != nil {
+			logger.Warn("Event consumer shutdown", "error", err)
+		}
+	}
+
+	// Stop expiring reservations before closing the channel it publishes on
+	s.stopSaga()
+	select {
+	case <-s.sagaDone:
+	case <-ctx.Done():
+		logger.Warn("Stock reservation saga shutdown", "error", ctx.Err())
+	}
+
+	if s.consumer != nil {
+		// Close the channel before the connection it belongs to
+		if err := s.ch.Close(); err != nil {
+			logger.Warn("RabbitMQ channel close", "error", err)
+		}
+		if err := s.conn.Close(); err != nil {
+			logger.Warn("RabbitMQ connection close", "error", err)
+		}
+	}
+
+	if sqlDB, err := s.dbConn.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			logger.Warn("Database close", "error", err)
+		}
+	}
+
+	// Flush the spans still buffered by the exporter
+	if err := s.shutdownTracing(ctx); err != nil {
+		logger.Warn("Tracing shutdown", "error", err)
+	}
+
+	logger.Info("Shutdown complete")
+}
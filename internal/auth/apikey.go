@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+)
+
+// Scope grants an API key access to a set of operations
+type Scope string
+
+const (
+	ScopeOrdersRead  Scope = "orders:read"
+	ScopeOrdersWrite Scope = "orders:write"
+	// ScopeAdmin has every permission
+	ScopeAdmin Scope = "admin"
+)
+
+// Scopes are the scopes an API key can have
+var Scopes = []Scope{ScopeOrdersRead, ScopeOrdersWrite, ScopeAdmin}
+
+// ParseScopes checks that every value is a known scope
+func ParseScopes(values []string) ([]Scope, error) {
+	scopes := make([]Scope, 0, len(values))
+	for _, value := range values {
+		scope := Scope(value)
+		if !slices.Contains(Scopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q, use orders:read, orders:write or admin", value)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to spot
+const apiKeyPrefix = "ptk_"
+
+// touchInterval limits how often the last-used date of a key is written
+const touchInterval = time.Minute
+
+// ErrInvalidAPIKey is returned for unknown, malformed, revoked and expired
+// keys alike
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// NewAPIKey generates a key and the record to store for it. The key is
+// ptk_<id>_<secret> and is only ever shown once.
+func NewAPIKey(name string, scopes []Scope, expiresAt *time.Time) (string, localModels.APIKey) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	_, _ = rand.Read(id)
+	_, _ = rand.Read(secret)
+
+	record := localModels.APIKey{
+		ID:        hex.EncodeToString(id),
+		Name:      name,
+		Scopes:    joinScopes(scopes),
+		ExpiresAt: expiresAt,
+	}
+	key := apiKeyPrefix + record.ID + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	record.Hash = hashAPIKey(key)
+	return key, record
+}
+
+// hashAPIKey hashes a key. Keys are random, so a fast hash is enough.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func joinScopes(scopes []Scope) string {
+	values := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		values = append(values, string(scope))
+	}
+	return strings.Join(values, " ")
+}
+
+// KeyScopes returns the scopes of a stored key
+func KeyScopes(key localModels.APIKey) []Scope {
+	var scopes []Scope
+	for _, value := range strings.Fields(key.Scopes) {
+		scopes = append(scopes, Scope(value))
+	}
+	return scopes
+}
+
+// APIKeyVerifier authenticates API keys against the stored ones
+type APIKeyVerifier struct {
+	keys   repository.APIKeyRepository
+	logger *slog.Logger
+}
+
+// NewAPIKeyVerifier creates an APIKeyVerifier for the keys in repo
+func NewAPIKeyVerifier(keys repository.APIKeyRepository, logger *slog.Logger) *APIKeyVerifier {
+	return &APIKeyVerifier{keys: keys, logger: logger}
+}
+
+// Verify checks the key and returns the principal of its service. The
+// last-used date of the key is updated at most once a minute.
+func (v *APIKeyVerifier) Verify(ctx context.Context, key string) (*Principal, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), "_")
+	if !strings.HasPrefix(key, apiKeyPrefix) || !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	record, err := v.keys.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(key)), []byte(record.Hash)) != 1 ||
+		record.RevokedAt != nil ||
+		(record.ExpiresAt != nil && !now.Before(*record.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= touchInterval {
+		if err := v.keys.Touch(ctx, record.ID, now.UTC()); err != nil {
+			// Not worth failing the request for
+			v.logger.WarnContext(ctx, "Failed to record API key use", "key_id", record.ID, "error", err)
+		}
+	}
+
+	return &Principal{Subject: "key:" + record.ID, Scopes: KeyScopes(record)}, nil
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Role grants access to a set of operations
+type Role string
+
+const (
+	// RoleCustomer reads its own orders
+	RoleCustomer Role = "customer"
+	// RoleSupport reads every order and writes them
+	RoleSupport Role = "support"
+	// RoleAdmin has every permission
+	RoleAdmin Role = "admin"
+)
+
+// roles are the known roles, other values found in tokens are ignored
+var roles = []Role{RoleCustomer, RoleSupport, RoleAdmin}
+
+// Principal is the authenticated client of a request. Users authenticated
+// by a token have roles, services authenticated by an API key have scopes.
+type Principal struct {
+	Subject string
+	Roles   []Role
+	Scopes  []Scope
+	// CustomerID is the customer a customer acts as, 0 when unknown
+	CustomerID uint
+}
+
+// HasRole tells whether the principal has any of roles
+func (p *Principal) HasRole(roles ...Role) bool {
+	return slices.ContainsFunc(p.Roles, func(role Role) bool { return slices.Contains(roles, role) })
+}
+
+// HasScope tells whether the principal has any of scopes
+func (p *Principal) HasScope(scopes ...Scope) bool {
+	return slices.ContainsFunc(p.Scopes, func(scope Scope) bool { return slices.Contains(scopes, scope) })
+}
+
+type contextKey string
+
+const principalKey contextKey = "auth/principal"
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// FromContext returns the principal carried by ctx. Requests to operations
+// without security requirements, and internal calls, have none.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok
+}
@@ -0,0 +1,210 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth/authtest"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTVerifier(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	verifier := issuer.Verifier()
+	ctx := context.Background()
+
+	p, err := verifier.Verify(ctx, issuer.Token(jwt.MapClaims{
+		"sub":         "alice",
+		"roles":       []string{"Customer", "unknown"},
+		"customer_id": 12,
+	}))
+	if err != nil {
+		t.Fatalf("expected token to verify, got %v", err)
+	}
+	if p.Subject != "alice" || !slices.Equal(p.Roles, []auth.Role{auth.RoleCustomer}) || p.CustomerID != 12 {
+		t.Errorf("expected customer alice with customer ID 12, got %+v", p)
+	}
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"expired":      {"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()},
+		"wrong issuer": {"sub": "alice", "iss": "https://other.test"},
+		"no subject":   {"roles": []string{"admin"}},
+	} {
+		if _, err := verifier.Verify(ctx, issuer.Token(claims)); err == nil {
+			t.Errorf("expected %s token to be rejected", name)
+		}
+	}
+
+	// Tokens from another key are rejected
+	if _, err := verifier.Verify(ctx, authtest.NewIssuer(t).Token(jwt.MapClaims{"sub": "mallory"})); err == nil {
+		t.Error("expected token signed by another key to be rejected")
+	}
+}
+
+func TestJWTVerifierNestedRolesFromFile(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, issuer.JWKS(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := issuer.Config()
+	cfg.JWKSFile = path
+	cfg.RolesClaim = "realm_access.roles"
+	keys, err := auth.LoadJWKS(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected JWKS to load, got %v", err)
+	}
+
+	p, err := auth.NewJWTVerifier(keys, cfg).Verify(context.Background(), issuer.Token(jwt.MapClaims{
+		"sub":          "bob",
+		"realm_access": map[string]any{"roles": []string{"support"}},
+	}))
+	if err != nil {
+		t.Fatalf("expected token to verify, got %v", err)
+	}
+	if !p.HasRole(auth.RoleSupport) || p.HasRole(auth.RoleAdmin) {
+		t.Errorf("expected support role only, got %v", p.Roles)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	_, api := humatest.New(t)
+	api.UseMiddleware(auth.Middleware(api, issuer.Verifier(), nil, slog.New(slog.DiscardHandler)))
+
+	huma.Register(api, huma.Operation{
+		Method:   http.MethodGet,
+		Path:     "/admin",
+		Security: auth.Require([]auth.Role{auth.RoleAdmin}, nil),
+	}, func(ctx context.Context, input *struct{}) (*struct{ Body string }, error) {
+		p, _ := auth.FromContext(ctx)
+		return &struct{ Body string }{Body: p.Subject}, nil
+	})
+	huma.Register(api, huma.Operation{
+		Method: http.MethodGet,
+		Path:   "/public",
+	}, func(ctx context.Context, input *struct{}) (*struct{}, error) {
+		return &struct{}{}, nil
+	})
+
+	if resp := api.Get("/public"); resp.Code != http.StatusNoContent {
+		t.Errorf("expected public route to be open, got %d", resp.Code)
+	}
+
+	resp := api.Get("/admin")
+	if resp.Code != http.StatusUnauthorized || resp.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("expected 401 with a challenge without a token, got %d", resp.Code)
+	}
+
+	if resp := api.Get("/admin", "Authorization: Bearer not-a-jwt"); resp.Code != http.StatusUnauthorized || strings.Contains(resp.Body.String(), "errors") {
+		t.Errorf("expected 401 without the reason for an invalid token, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	support := issuer.Authorization(auth.Principal{Subject: "bob", Roles: []auth.Role{auth.RoleSupport}})
+	if resp := api.Get("/admin", support); resp.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the admin role, got %d", resp.Code)
+	}
+
+	admin := issuer.Authorization(auth.Principal{Subject: "carol", Roles: []auth.Role{auth.RoleAdmin}})
+	resp = api.Get("/admin", admin)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != `"carol"` {
+		t.Errorf("expected the principal to reach the handler, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestAPIKeyVerifier(t *testing.T) {
+	ctx := context.Background()
+	keys := repository.NewMemoryAPIKeyRepository()
+	verifier := auth.NewAPIKeyVerifier(keys, slog.New(slog.DiscardHandler))
+
+	key, record := auth.NewAPIKey("billing", []auth.Scope{auth.ScopeOrdersRead}, nil)
+	if err := keys.Create(ctx, &record); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := verifier.Verify(ctx, key)
+	if err != nil {
+		t.Fatalf("expected key to verify, got %v", err)
+	}
+	if p.Subject != "key:"+record.ID || !p.HasScope(auth.ScopeOrdersRead) || p.HasScope(auth.ScopeOrdersWrite) {
+		t.Errorf("expected read-only principal of the key, got %+v", p)
+	}
+	if stored, _ := keys.Get(ctx, record.ID); stored.LastUsedAt == nil {
+		t.Error("expected the last-used date to be recorded")
+	}
+
+	for name, key := range map[string]string{
+		"wrong secret": key[:len(key)-4] + "AAAA",
+		"unknown id":   "ptk_000000000000_secret",
+		"malformed":    "not-a-key",
+	} {
+		if _, err := verifier.Verify(ctx, key); !errors.Is(err, auth.ErrInvalidAPIKey) {
+			t.Errorf("expected %s to be rejected, got %v", name, err)
+		}
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	expiredKey, expiredRecord := auth.NewAPIKey("old", []auth.Scope{auth.ScopeAdmin}, &expired)
+	if err := keys.Create(ctx, &expiredRecord); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(ctx, expiredKey); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("expected expired key to be rejected, got %v", err)
+	}
+
+	if err := keys.Revoke(ctx, record.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(ctx, key); !errors.Is(err, auth.ErrInvalidAPIKey) {
+		t.Errorf("expected revoked key to be rejected, got %v", err)
+	}
+}
+
+func TestMiddlewareAPIKey(t *testing.T) {
+	ctx := context.Background()
+	keys := repository.NewMemoryAPIKeyRepository()
+	_, api := humatest.New(t)
+	api.UseMiddleware(auth.Middleware(api, authtest.NewIssuer(t).Verifier(), auth.NewAPIKeyVerifier(keys, slog.New(slog.DiscardHandler)), slog.New(slog.DiscardHandler)))
+
+	huma.Register(api, huma.Operation{
+		Method:   http.MethodPost,
+		Path:     "/orders",
+		Security: auth.Require([]auth.Role{auth.RoleAdmin}, []auth.Scope{auth.ScopeOrdersWrite}),
+	}, func(ctx context.Context, input *struct{}) (*struct{ Body string }, error) {
+		p, _ := auth.FromContext(ctx)
+		return &struct{ Body string }{Body: p.Subject}, nil
+	})
+
+	reader, readRecord := auth.NewAPIKey("reporting", []auth.Scope{auth.ScopeOrdersRead}, nil)
+	writer, writeRecord := auth.NewAPIKey("checkout", []auth.Scope{auth.ScopeOrdersWrite}, nil)
+	for _, record := range []*localModels.APIKey{&readRecord, &writeRecord} {
+		if err := keys.Create(ctx, record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if resp := api.Post("/orders", "Authorization: ApiKey ptk_000000000000_nope"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an unknown key, got %d", resp.Code)
+	}
+	if resp := api.Post("/orders", "Authorization: ApiKey "+reader); resp.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without the orders:write scope, got %d", resp.Code)
+	}
+	resp := api.Post("/orders", "Authorization: ApiKey "+writer)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != `"key:`+writeRecord.ID+`"` {
+		t.Errorf("expected the key principal to reach the handler, got %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,116 @@
+// Package authtest issues tokens for tests
+package authtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// IssuerURL is the iss claim of the tokens and the issuer expected by
+	// the verifier
+	IssuerURL = "https://issuer.test"
+	keyID     = "test"
+)
+
+// Issuer signs tokens with a key generated for the test
+type Issuer struct {
+	t   testing.TB
+	key *ecdsa.PrivateKey
+}
+
+// NewIssuer creates an Issuer with a new ES256 key
+func NewIssuer(t testing.TB) *Issuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Issuer{t: t, key: key}
+}
+
+// Config returns the auth configuration trusting the issuer, with the
+// default claims
+func (i *Issuer) Config() config.Auth {
+	return config.Auth{Issuer: IssuerURL, RolesClaim: "roles", CustomerClaim: "customer_id"}
+}
+
+// JWKS returns the JWKS document holding the public key of the issuer
+func (i *Issuer) JWKS() []byte {
+	i.t.Helper()
+	pub, err := i.key.PublicKey.ECDH()
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	// Uncompressed point: 0x04, then X and Y
+	point := pub.Bytes()
+	encode := base64.RawURLEncoding.EncodeToString
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": keyID,
+		"alg": "ES256",
+		"use": "sig",
+		"x":   encode(point[1:33]),
+		"y":   encode(point[33:]),
+	}}})
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return jwks
+}
+
+// Verifier returns a verifier trusting the issuer, going through its JWKS
+func (i *Issuer) Verifier() *auth.JWTVerifier {
+	i.t.Helper()
+	k, err := keyfunc.NewJWKSetJSON(i.JWKS())
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return auth.NewJWTVerifier(k.Keyfunc, i.Config())
+}
+
+// Token signs claims, adding the issuer and an expiry in an hour unless set
+func (i *Issuer) Token(claims jwt.MapClaims) string {
+	i.t.Helper()
+	if _, ok := claims["iss"]; !ok {
+		claims["iss"] = IssuerURL
+	}
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = keyID
+	signed, err := token.SignedString(i.key)
+	if err != nil {
+		i.t.Fatal(err)
+	}
+	return signed
+}
+
+// Authorization returns the Authorization header, as accepted by humatest,
+// of a token for p
+func (i *Issuer) Authorization(p auth.Principal) string {
+	roles := make([]string, 0, len(p.Roles))
+	for _, role := range p.Roles {
+		roles = append(roles, string(role))
+	}
+	claims := jwt.MapClaims{"sub": p.Subject, "roles": roles}
+	if p.CustomerID != 0 {
+		claims["customer_id"] = strconv.FormatUint(uint64(p.CustomerID), 10)
+	}
+	return "Authorization: Bearer " + i.Token(claims)
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v3"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway tolerates clock skew with the token issuer
+const leeway = 30 * time.Second
+
+// LoadJWKS returns the function looking up the keys verifying tokens, from
+// the JWKS document at cfg.JWKSURL or in cfg.JWKSFile. Remote documents are
+// refreshed in the background until ctx is done.
+func LoadJWKS(ctx context.Context, cfg config.Auth) (jwt.Keyfunc, error) {
+	if cfg.JWKSURL != "" {
+		k, err := keyfunc.NewDefaultCtx(ctx, []string{cfg.JWKSURL})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWKS from %s: %w", cfg.JWKSURL, err)
+		}
+		return k.Keyfunc, nil
+	}
+
+	data, err := os.ReadFile(cfg.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	k, err := keyfunc.NewJWKSetJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS in %s: %w", cfg.JWKSFile, err)
+	}
+	return k.Keyfunc, nil
+}
+
+// JWTVerifier verifies bearer tokens and maps their claims to a Principal
+type JWTVerifier struct {
+	keys          jwt.Keyfunc
+	parser        *jwt.Parser
+	rolesClaim    []string
+	customerClaim string
+}
+
+// NewJWTVerifier creates a JWTVerifier checking signatures with keys and the
+// issuer and audience set in cfg
+func NewJWTVerifier(keys jwt.Keyfunc, cfg config.Auth) *JWTVerifier {
+	options := []jwt.ParserOption{
+		jwt.WithLeeway(leeway),
+		jwt.WithExpirationRequired(),
+		// Only asymmetric algorithms, the keys are public
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTVerifier{
+		keys:          keys,
+		parser:        jwt.NewParser(options...),
+		rolesClaim:    strings.Split(cfg.RolesClaim, "."),
+		customerClaim: cfg.CustomerClaim,
+	}
+}
+
+// Verify checks the token and returns the principal it identifies
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keys); err != nil {
+		return nil, err
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+
+	p := &Principal{Subject: subject}
+	for _, value := range stringList(lookup(claims, v.rolesClaim)) {
+		// Roles are matched regardless of case, e.g. ADMIN or Admin
+		role := Role(strings.ToLower(value))
+		for _, known := range roles {
+			if role == known {
+				p.Roles = append(p.Roles, role)
+			}
+		}
+	}
+
+	if p.HasRole(RoleCustomer) {
+		p.CustomerID = customerID(claims[v.customerClaim])
+	}
+
+	return p, nil
+}
+
+// lookup returns the claim at path, descending into nested objects
+func lookup(claims map[string]any, path []string) any {
+	var value any = claims
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// stringList reads a claim holding either a list of strings or a space
+// separated string, like the scope claim
+func stringList(value any) []string {
+	switch v := value.(type) {
+	case string:
+		return strings.Fields(v)
+	case []any:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// customerID reads a customer ID given as a number or a string, 0 when it
+// is neither
+func customerID(value any) uint {
+	switch v := value.(type) {
+	case float64:
+		if v > 0 && v == float64(uint(v)) {
+			return uint(v)
+		}
+	case string:
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err == nil {
+			return uint(id)
+		}
+	}
+	return 0
+}
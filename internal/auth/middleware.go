@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Security schemes. In the security requirement of an operation, the
+// scopes of BearerScheme are the roles allowed to call it and the scopes
+// of APIKeyScheme the API key scopes.
+const (
+	BearerScheme = "bearer"
+	APIKeyScheme = "apiKey"
+)
+
+// Verifier authenticates the credentials of a request
+type Verifier interface {
+	Verify(ctx context.Context, credentials string) (*Principal, error)
+}
+
+// RegisterSecuritySchemes documents the security schemes in the OpenAPI spec
+func RegisterSecuritySchemes(openapi *huma.OpenAPI) {
+	if openapi.Components.SecuritySchemes == nil {
+		openapi.Components.SecuritySchemes = map[string]*huma.SecurityScheme{}
+	}
+	openapi.Components.SecuritySchemes[BearerScheme] = &huma.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "JWT whose roles claim holds customer, support or admin. Customers only see their own orders.",
+	}
+	openapi.Components.SecuritySchemes[APIKeyScheme] = &huma.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "Authorization",
+		Description: "API key of a service, sent as `Authorization: ApiKey ptk_...`, with the orders:read, orders:write or admin scopes.",
+	}
+}
+
+// Require returns the security requirement of an operation open to the
+// users with one of roles and to the API keys with one of scopes
+func Require(roles []Role, scopes []Scope) []map[string][]string {
+	roleNames := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleNames = append(roleNames, string(role))
+	}
+	scopeNames := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scopeNames = append(scopeNames, string(scope))
+	}
+	return []map[string][]string{{BearerScheme: roleNames}, {APIKeyScheme: scopeNames}}
+}
+
+// requirements returns what op requires by security scheme, and false when
+// it is open
+func requirements(op *huma.Operation) (map[string][]string, bool) {
+	required := map[string][]string{}
+	for _, requirement := range op.Security {
+		for scheme, scopes := range requirement {
+			if scheme == BearerScheme || scheme == APIKeyScheme {
+				required[scheme] = scopes
+			}
+		}
+	}
+	return required, len(required) > 0
+}
+
+// allowed tells whether p has one of the roles or scopes required by scheme
+func allowed(p *Principal, scheme string, required []string) bool {
+	if len(required) == 0 {
+		return true
+	}
+	for _, name := range required {
+		if scheme == BearerScheme && p.HasRole(Role(name)) || scheme == APIKeyScheme && p.HasScope(Scope(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware authenticates the requests to operations with a security
+// requirement, with tokens for bearer tokens and keys for API keys, checks
+// they have one of the required roles or scopes and puts the Principal on
+// the context. Why credentials are rejected is only logged, at debug level.
+func Middleware(api huma.API, tokens, keys Verifier, logger *slog.Logger) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		required, ok := requirements(ctx.Operation())
+		if !ok {
+			next(ctx)
+			return
+		}
+
+		authScheme, credentials, _ := strings.Cut(ctx.Header("Authorization"), " ")
+		var scheme string
+		var verifier Verifier
+		switch {
+		case strings.EqualFold(authScheme, "Bearer"):
+			scheme, verifier = BearerScheme, tokens
+		case strings.EqualFold(authScheme, "ApiKey"):
+			scheme, verifier = APIKeyScheme, keys
+		}
+
+		scopes, accepted := required[scheme]
+		if !accepted || verifier == nil || credentials == "" {
+			ctx.SetHeader("WWW-Authenticate", `Bearer, ApiKey`)
+			huma.WriteErr(api, ctx, http.StatusUnauthorized, "A bearer token or an API key is required")
+			return
+		}
+
+		p, err := verifier.Verify(ctx.Context(), credentials)
+		if err != nil {
+			ctx.SetHeader("WWW-Authenticate", authScheme+` error="invalid_token"`)
+			logger.DebugContext(ctx.Context(), "Credentials rejected", "scheme", scheme, "error", err)
+			huma.WriteErr(api, ctx, http.StatusUnauthorized, "Invalid credentials")
+			return
+		}
+
+		if !allowed(p, scheme, scopes) {
+			kind := "roles"
+			if scheme == APIKeyScheme {
+				kind = "scopes"
+			}
+			huma.WriteErr(api, ctx, http.StatusForbidden, "One of these "+kind+" is required: "+strings.Join(scopes, ", "))
+			return
+		}
+
+		next(huma.WithContext(ctx, WithPrincipal(ctx.Context(), p)))
+	}
+}
+
+// AnonymousSubject is the subject of the principal of Anonymous
+const AnonymousSubject = "anonymous"
+
+// Anonymous stands in for Middleware when authentication is disabled. Every
+// request acts as an admin.
+func Anonymous() func(huma.Context, func(huma.Context)) {
+	admin := &Principal{Subject: AnonymousSubject, Roles: []Role{RoleAdmin}, Scopes: []Scope{ScopeAdmin}}
+	return func(ctx huma.Context, next func(huma.Context)) {
+		if _, ok := requirements(ctx.Operation()); !ok {
+			next(ctx)
+			return
+		}
+		next(huma.WithContext(ctx, WithPrincipal(ctx.Context(), admin)))
+	}
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Event brokers
+const (
+	// BrokerRabbitMQ exchanges events with the other services through RabbitMQ
+	BrokerRabbitMQ = "rabbitmq"
+	// BrokerMemory keeps events in process, for running without RabbitMQ
+	BrokerMemory = "memory"
+)
+
+// Config is the configuration of the service. Every value has a default, can
+// be set in the YAML file and overridden by its environment variable, then by
+// its command line flag.
+type Config struct {
+	HTTP      HTTP      `yaml:"http"`
+	Database  Database  `yaml:"database"`
+	Events    Events    `yaml:"events"`
+	RabbitMQ  RabbitMQ  `yaml:"rabbitmq"`
+	Products  Products  `yaml:"products"`
+	Orders    Orders    `yaml:"orders"`
+	Auth      Auth      `yaml:"auth"`
+	RateLimit RateLimit `yaml:"rateLimit"`
+	Log       Log       `yaml:"log"`
+	Tracing   Tracing   `yaml:"tracing"`
+}
+
+// HTTP configures the HTTP server
+type HTTP struct {
+	Port            int           `yaml:"port" env:"SERVICE_PORT" flag:"port" short:"p" default:"8082" doc:"Port to listen on"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SERVICE_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" default:"15s" doc:"Time allowed to drain requests and events on shutdown"`
+	HealthTimeout   time.Duration `yaml:"healthTimeout" env:"SERVICE_HEALTH_TIMEOUT" flag:"health-timeout" default:"2s" doc:"Time allowed to each readiness check"`
+}
+
+// Database configures the connection to the database
+type Database struct {
+	DSN            string `yaml:"dsn" env:"DATABASE_DSN" flag:"database-dsn" secret:"true" doc:"Database connection string, postgres://... for PostgreSQL or sqlite://<path> for SQLite"`
+	MigrateOnStart bool   `yaml:"migrateOnStart" env:"DATABASE_MIGRATE_ON_START" flag:"migrate-on-start" default:"false" doc:"Apply pending migrations when the server starts instead of refusing to start"`
+}
+
+// Events configures how events are exchanged
+type Events struct {
+	Broker string `yaml:"broker" env:"EVENTS_BROKER" flag:"broker" default:"rabbitmq" doc:"Event broker: rabbitmq, or memory for an in-process bus fed through POST /events/{routingKey}"`
+}
+
+// RabbitMQ configures the connection to the broker and the event consumer
+type RabbitMQ struct {
+	DSN            string        `yaml:"dsn" env:"RABBIT_DSN" flag:"rabbit-dsn" secret:"true" doc:"RabbitMQ connection URL"`
+	Exchange       string        `yaml:"exchange" env:"RABBIT_EXCHANGE" flag:"rabbit-exchange" default:"events" doc:"Topic exchange events are published to and consumed from"`
+	Prefetch       int           `yaml:"prefetch" env:"RABBIT_PREFETCH" flag:"rabbit-prefetch" default:"10" doc:"Number of unacknowledged deliveries per consumer"`
+	HandlerTimeout time.Duration `yaml:"handlerTimeout" env:"RABBIT_HANDLER_TIMEOUT" flag:"rabbit-handler-timeout" default:"30s" doc:"Time allowed to an event handler"`
+}
+
+// Products configures the client of the Products service
+type Products struct {
+	URL     string        `yaml:"url" env:"PRODUCTS_URL" flag:"products-url" doc:"Base URL of the Products service"`
+	Timeout time.Duration `yaml:"timeout" env:"PRODUCTS_TIMEOUT" flag:"products-timeout" default:"5s" doc:"Timeout of requests to the Products service"`
+}
+
+// Orders configures the limits of the orders accepted and how long deleted
+// ones are kept
+type Orders struct {
+	MaxLines    int           `yaml:"maxLines" env:"ORDERS_MAX_LINES" flag:"orders-max-lines" default:"50" doc:"Maximum number of product lines of an order"`
+	MaxQuantity int           `yaml:"maxQuantity" env:"ORDERS_MAX_QUANTITY" flag:"orders-max-quantity" default:"1000" doc:"Maximum total quantity of the products of an order"`
+	Retention   time.Duration `yaml:"retention" env:"ORDERS_RETENTION" flag:"orders-retention" default:"2160h" doc:"Time deleted orders are kept before a purge removes them for good"`
+	Reservation Reservation   `yaml:"reservation"`
+}
+
+// Reservation configures the reservation of the stock of new orders by the
+// Products service, which confirms or cancels them
+type Reservation struct {
+	Enabled bool          `yaml:"enabled" env:"ORDERS_RESERVATION_ENABLED" flag:"orders-reservation" default:"false" doc:"Keep new orders pending until the Products service reserves their stock, only once it publishes product.stock.reserved and product.stock.rejected, as unanswered orders are cancelled"`
+	Timeout time.Duration `yaml:"timeout" env:"ORDERS_RESERVATION_TIMEOUT" flag:"orders-reservation-timeout" default:"5m" doc:"Time given to the Products service to reserve the stock of an order before it is cancelled"`
+}
+
+// Auth configures how API clients are authenticated. Bearer tokens are JWTs
+// verified with the keys of a JWKS document.
+type Auth struct {
+	Disabled      bool   `yaml:"disabled" env:"AUTH_DISABLED" flag:"auth-disabled" default:"false" doc:"Let every request in as an admin, for local development only"`
+	JWKSURL       string `yaml:"jwksUrl" env:"AUTH_JWKS_URL" flag:"auth-jwks-url" doc:"URL of the JWKS document verifying tokens, refreshed hourly"`
+	JWKSFile      string `yaml:"jwksFile" env:"AUTH_JWKS_FILE" flag:"auth-jwks-file" doc:"Path of a JWKS document verifying tokens, instead of jwksUrl"`
+	Issuer        string `yaml:"issuer" env:"AUTH_ISSUER" flag:"auth-issuer" doc:"Expected iss claim, not checked when empty"`
+	Audience      string `yaml:"audience" env:"AUTH_AUDIENCE" flag:"auth-audience" doc:"Expected aud claim, not checked when empty"`
+	RolesClaim    string `yaml:"rolesClaim" env:"AUTH_ROLES_CLAIM" flag:"auth-roles-claim" default:"roles" doc:"Claim listing the roles, dotted for nested claims, e.g. realm_access.roles"`
+	CustomerClaim string `yaml:"customerClaim" env:"AUTH_CUSTOMER_CLAIM" flag:"auth-customer-claim" default:"customer_id" doc:"Claim holding the customer ID of customers"`
+}
+
+// RateLimit configures the token buckets limiting the requests of each
+// client, identified by its API key, the subject of its token or its IP
+// address
+type RateLimit struct {
+	Enabled bool    `yaml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit" default:"true" doc:"Limit the requests of each client"`
+	Rate    float64 `yaml:"rate" env:"RATE_LIMIT_RATE" flag:"rate-limit-rate" default:"10" doc:"Requests per second allowed to a client, shared by the operations without their own limit"`
+	Burst   int     `yaml:"burst" env:"RATE_LIMIT_BURST" flag:"rate-limit-burst" default:"20" doc:"Requests a client may send at once"`
+	// Routes are set in the config file only
+	Routes         map[string]RouteLimit `yaml:"routes" doc:"Limits by operation ID, e.g. get-orders, each with a bucket of its own"`
+	TrustedProxies []string              `yaml:"trustedProxies" env:"RATE_LIMIT_TRUSTED_PROXIES" flag:"rate-limit-trusted-proxies" doc:"Addresses or CIDRs of the proxies, e.g. the gateway, whose X-Forwarded-For header gives the IP of the client"`
+	PreAuth        PreAuthLimit          `yaml:"preAuth"`
+}
+
+// PreAuthLimit limits the requests of each IP address before they are
+// authenticated. It is looser than the limit of a client, as several
+// clients may share an address.
+type PreAuthLimit struct {
+	Rate  float64 `yaml:"rate" env:"RATE_LIMIT_PRE_AUTH_RATE" flag:"rate-limit-pre-auth-rate" default:"50" doc:"Requests per second allowed to an IP address before authentication"`
+	Burst int     `yaml:"burst" env:"RATE_LIMIT_PRE_AUTH_BURST" flag:"rate-limit-pre-auth-burst" default:"100" doc:"Requests an IP address may send at once before authentication"`
+}
+
+// RouteLimit is the limit of an operation
+type RouteLimit struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+// Log configures the service logger
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" default:"info" doc:"Log level: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" default:"json" doc:"Log format: json or text"`
+}
+
+// Tracing configures where spans are exported. The OTLP exporter reads its
+// endpoint and headers from the standard OTEL_EXPORTER_OTLP_* variables.
+type Tracing struct {
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" flag:"traces-exporter" default:"none" doc:"Span exporter: none, otlp, stdout or file"`
+	File     string `yaml:"file" env:"OTEL_TRACES_FILE" flag:"traces-file" default:"traces.jsonl" doc:"File spans are written to with the file exporter"`
+}
+
+// Validate checks every value and reports all the invalid ones at once
+func (c *Config) Validate() error {
+	v := validator{fields: index(c)}
+
+	v.check(c.HTTP.Port > 0 && c.HTTP.Port <= 65535, "http.port", "must be between 1 and 65535")
+	v.check(c.HTTP.ShutdownTimeout > 0, "http.shutdownTimeout", "must be positive")
+	v.check(c.HTTP.HealthTimeout > 0, "http.healthTimeout", "must be positive")
+
+	v.required(c.Database.DSN, "database.dsn")
+
+	v.oneOf(c.Events.Broker, "events.broker", BrokerRabbitMQ, BrokerMemory)
+
+	// The memory broker still names the exchange of the events it carries
+	if c.Events.Broker == BrokerRabbitMQ && v.required(c.RabbitMQ.DSN, "rabbitmq.dsn") {
+		v.url(c.RabbitMQ.DSN, "rabbitmq.dsn", "amqp", "amqps")
+	}
+	v.required(c.RabbitMQ.Exchange, "rabbitmq.exchange")
+	v.check(c.RabbitMQ.Prefetch > 0, "rabbitmq.prefetch", "must be positive")
+	v.check(c.RabbitMQ.HandlerTimeout > 0, "rabbitmq.handlerTimeout", "must be positive")
+
+	if v.required(c.Products.URL, "products.url") {
+		v.url(c.Products.URL, "products.url", "http", "https")
+	}
+	v.check(c.Products.Timeout > 0, "products.timeout", "must be positive")
+
+	v.check(c.Orders.MaxLines > 0, "orders.maxLines", "must be positive")
+	v.check(c.Orders.MaxQuantity >= c.Orders.MaxLines, "orders.maxQuantity", "must be at least orders.maxLines")
+	v.check(c.Orders.Retention > 0, "orders.retention", "must be positive")
+	if c.Orders.Reservation.Enabled {
+		v.check(c.Orders.Reservation.Timeout > 0, "orders.reservation.timeout", "must be positive")
+	}
+
+	if !c.Auth.Disabled {
+		v.check(c.Auth.JWKSURL != "" || c.Auth.JWKSFile != "", "auth.jwksUrl",
+			"is required unless auth.jwksFile is set, set auth.jwksUrl in the config file, AUTH_JWKS_URL or --auth-jwks-url")
+		v.check(c.Auth.JWKSURL == "" || c.Auth.JWKSFile == "", "auth.jwksFile", "can't be set along with auth.jwksUrl")
+		if c.Auth.JWKSURL != "" {
+			v.url(c.Auth.JWKSURL, "auth.jwksUrl", "http", "https")
+		}
+		v.required(c.Auth.RolesClaim, "auth.rolesClaim")
+		v.required(c.Auth.CustomerClaim, "auth.customerClaim")
+	}
+
+	if c.RateLimit.Enabled {
+		v.check(c.RateLimit.Rate > 0, "rateLimit.rate", "must be positive")
+		v.check(c.RateLimit.Burst > 0, "rateLimit.burst", "must be positive")
+		v.check(c.RateLimit.PreAuth.Rate > 0, "rateLimit.preAuth.rate", "must be positive")
+		v.check(c.RateLimit.PreAuth.Burst > 0, "rateLimit.preAuth.burst", "must be positive")
+		for _, operation := range slices.Sorted(maps.Keys(c.RateLimit.Routes)) {
+			limit := c.RateLimit.Routes[operation]
+			v.check(limit.Rate > 0, "rateLimit.routes."+operation+".rate", "must be positive")
+			v.check(limit.Burst > 0, "rateLimit.routes."+operation+".burst", "must be positive")
+		}
+		for _, proxy := range c.RateLimit.TrustedProxies {
+			_, prefixErr := netip.ParsePrefix(proxy)
+			_, addrErr := netip.ParseAddr(proxy)
+			v.check(prefixErr == nil || addrErr == nil, "rateLimit.trustedProxies", "expected addresses or CIDRs, got "+proxy)
+		}
+	}
+
+	v.oneOf(strings.ToLower(c.Log.Level), "log.level", "debug", "info", "warn", "error")
+	v.oneOf(strings.ToLower(c.Log.Format), "log.format", "json", "text")
+
+	v.oneOf(c.Tracing.Exporter, "tracing.exporter", "none", "otlp", "stdout", "file")
+	if c.Tracing.Exporter == "file" {
+		v.required(c.Tracing.File, "tracing.file")
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects the errors found by Validate
+type validator struct {
+	fields map[string]field
+	errs   []error
+}
+
+func (v *validator) check(ok bool, path, msg string) bool {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: %s", path, msg))
+	}
+	return ok
+}
+
+// required reports an empty value along with the ways to set it
+func (v *validator) required(value, path string) bool {
+	f := v.fields[path]
+	return v.check(value != "", path, fmt.Sprintf("is required, set %s in the config file, %s or --%s", path, f.env, f.flag))
+}
+
+func (v *validator) url(value, path string, schemes ...string) bool {
+	u, err := url.Parse(value)
+	if err != nil {
+		// Don't echo the value back, it may contain credentials
+		return v.check(false, path, "is not a valid URL")
+	}
+	return v.check(slices.Contains(schemes, u.Scheme) && u.Host != "", path,
+		fmt.Sprintf("must be an absolute %s URL", strings.Join(schemes, " or ")))
+}
+
+func (v *validator) oneOf(value, path string, allowed ...string) bool {
+	return v.check(slices.Contains(allowed, value), path,
+		fmt.Sprintf("must be one of %s, got %q", strings.Join(allowed, ", "), value))
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// field is a leaf value of Config along with the tags describing it
+type field struct {
+	// path is the dotted YAML path, e.g. database.dsn
+	path   string
+	env    string
+	flag   string
+	short  string
+	def    string
+	doc    string
+	secret bool
+	value  reflect.Value
+}
+
+// index returns the fields of c by path
+func index(c *Config) map[string]field {
+	fields := map[string]field{}
+	for _, f := range walk(reflect.ValueOf(c).Elem(), "") {
+		fields[f.path] = f
+	}
+	return fields
+}
+
+// walk lists the leaf fields of the struct v in declaration order
+func walk(v reflect.Value, prefix string) []field {
+	var fields []field
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		path := prefix + name
+
+		if sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, walk(v.Field(i), path+".")...)
+			continue
+		}
+
+		fields = append(fields, field{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			flag:   sf.Tag.Get("flag"),
+			short:  sf.Tag.Get("short"),
+			def:    sf.Tag.Get("default"),
+			doc:    sf.Tag.Get("doc"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+// set parses s into the value of f
+func (f field) set(s string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int, v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for item := range strings.SplitSeq(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// BindFlags registers a flag on fs for every value of Config that has one
+func BindFlags(fs *pflag.FlagSet) {
+	var c Config
+	for _, f := range walk(reflect.ValueOf(&c).Elem(), "") {
+		if f.flag == "" {
+			continue
+		}
+
+		usage := f.doc
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+
+		switch {
+		case f.value.Type() == durationType:
+			d, _ := time.ParseDuration(f.def)
+			fs.DurationP(f.flag, f.short, d, usage)
+		case f.value.Kind() == reflect.Int:
+			n, _ := strconv.Atoi(f.def)
+			fs.IntP(f.flag, f.short, n, usage)
+		case f.value.Kind() == reflect.Bool:
+			b, _ := strconv.ParseBool(f.def)
+			fs.BoolP(f.flag, f.short, b, usage)
+		case f.value.Kind() == reflect.Float64:
+			n, _ := strconv.ParseFloat(f.def, 64)
+			fs.Float64P(f.flag, f.short, n, usage)
+		default:
+			fs.StringP(f.flag, f.short, f.def, usage)
+		}
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path when
+// it isn't empty, the environment and the flags of fs that were set, in that
+// order, then validates it. fs may be nil.
+func Load(path string, fs *pflag.FlagSet) (*Config, error) {
+	c := &Config{}
+	fields := walk(reflect.ValueOf(c).Elem(), "")
+
+	for _, f := range fields {
+		if f.def == "" {
+			continue
+		}
+		if err := f.set(f.def); err != nil {
+			return nil, fmt.Errorf("invalid default for %s: %w", f.path, err)
+		}
+	}
+
+	if path != "" {
+		if err := c.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, f := range fields {
+		if s, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if err := f.set(s); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value in %s: %w", f.path, f.env, err))
+			}
+		}
+		if fs != nil && f.flag != "" && fs.Changed(f.flag) {
+			if err := f.set(fs.Lookup(f.flag).Value.String()); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid value for --%s: %w", f.path, f.flag, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	return c, nil
+}
+
+// loadFile decodes the YAML file at path over c, rejecting unknown keys
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secrets in the output of Print
+const Redacted = "REDACTED"
+
+// Print writes c as YAML to w, in the format read by Load, with secrets
+// redacted
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, f := range walk(reflect.ValueOf(c).Elem(), "") {
+		value, err := f.node()
+		if err != nil {
+			return fmt.Errorf("failed to print %s: %w", f.path, err)
+		}
+		parent := mapping(root, strings.Split(f.path, "."))
+		parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: lastSegment(f.path)}, value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// mapping returns the mapping node holding the last segment of path,
+// creating the intermediate ones
+func mapping(root *yaml.Node, path []string) *yaml.Node {
+	node := root
+	for _, key := range path[:len(path)-1] {
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, next)
+		}
+		node = next
+	}
+	return node
+}
+
+func lastSegment(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+// node renders the value of f, keeping durations human readable
+func (f field) node() (*yaml.Node, error) {
+	v := f.value
+	switch {
+	case f.secret:
+		return scalar("!!str", redact(v.String())), nil
+	case v.Type() == durationType:
+		return scalar("!!str", time.Duration(v.Int()).String()), nil
+	case v.Kind() == reflect.String:
+		return scalar("!!str", v.String()), nil
+	case v.Kind() == reflect.Int, v.Kind() == reflect.Int64:
+		return scalar("!!int", strconv.FormatInt(v.Int(), 10)), nil
+	case v.Kind() == reflect.Bool:
+		return scalar("!!bool", strconv.FormatBool(v.Bool())), nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(v.Interface()); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func scalar(tag, value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+}
+
+// redact hides the password of a URL, in its user info or query, and any
+// other secret entirely
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if u, err := url.Parse(secret); err == nil && u.Scheme != "" && u.Host != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+		}
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", Redacted)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return Redacted
+}
@@ -0,0 +1,71 @@
+package correlation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header names used to carry the correlation ID and W3C trace context
+const (
+	HeaderRequestID   = "X-Request-ID"
+	HeaderTraceparent = "traceparent"
+
+	// AMQPHeaderRequestID is the AMQP header carrying the request ID
+	AMQPHeaderRequestID = "x-request-id"
+)
+
+type contextKey string
+
+const idKey contextKey = "correlation/id"
+
+// traceparentPattern matches a W3C traceparent header
+var traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+
+// WithID returns a copy of ctx carrying the correlation ID
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey, id)
+}
+
+// ID returns the correlation ID carried by ctx, or an empty string
+func ID(ctx context.Context) string {
+	id, _ := ctx.Value(idKey).(string)
+	return id
+}
+
+// TraceID extracts the trace ID from a W3C traceparent, or returns an
+// empty string when it is malformed
+func TraceID(traceparent string) string {
+	matches := traceparentPattern.FindStringSubmatch(traceparent)
+	if matches == nil {
+		return ""
+	}
+	return matches[1]
+}
+
+// NewID returns a random 128-bit ID hex encoded, the same shape as a W3C
+// trace ID
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Resolve picks the correlation ID from an explicit request ID, then the
+// trace ID of the span in ctx, then the trace ID of traceparent, and
+// generates a new one when all are missing
+func Resolve(ctx context.Context, requestID, traceparent string) string {
+	if requestID != "" {
+		return requestID
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	if traceID := TraceID(traceparent); traceID != "" {
+		return traceID
+	}
+	return NewID()
+}
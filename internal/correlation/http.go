@@ -0,0 +1,17 @@
+package correlation
+
+import "net/http"
+
+// Middleware takes the correlation ID from the X-Request-ID header, the
+// current trace or the traceparent header, or generates one. The ID is put
+// on the request context and echoed in the X-Request-ID response header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := Resolve(r.Context(), r.Header.Get(HeaderRequestID), r.Header.Get(HeaderTraceparent))
+
+		ctx := WithID(r.Context(), id)
+
+		w.Header().Set(HeaderRequestID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,49 @@
+package correlation_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+)
+
+func TestMiddleware(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	cases := []struct {
+		name     string
+		headers  map[string]string
+		expectID string
+	}{
+		{"request id", map[string]string{correlation.HeaderRequestID: "abc"}, "abc"},
+		{"traceparent", map[string]string{correlation.HeaderTraceparent: traceparent}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"generated", map[string]string{correlation.HeaderTraceparent: "garbage"}, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotID string
+			handler := correlation.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotID = correlation.ID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if tc.expectID != "" && gotID != tc.expectID {
+				t.Errorf("expected ID %q, got %q", tc.expectID, gotID)
+			}
+			if gotID == "" {
+				t.Error("expected a correlation ID")
+			}
+			if rec.Header().Get(correlation.HeaderRequestID) != gotID {
+				t.Errorf("expected response header %q, got %q", gotID, rec.Header().Get(correlation.HeaderRequestID))
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Package dbtest provides databases for tests
+package dbtest
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db"
+	"gorm.io/gorm"
+)
+
+// New returns an in-memory SQLite database with every migration applied,
+// private to the test and closed when it ends
+func New(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	gormDB, err := db.Open("sqlite://:memory:", slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("failed to load migrations: %v", err)
+	}
+	if _, err := db.NewMigrator(gormDB, migrations).Up(context.Background()); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	return gormDB
+}
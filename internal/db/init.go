@@ -1,26 +1,108 @@
 package db
 
 import (
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/plugin/opentelemetry/tracing"
 
-	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
-
-	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
 )
 
-func Init() *gorm.DB {
-	dsn := os.Getenv("DATABASE_DSN")
+// Init connects to the database. The schema is managed by the migrations,
+// see Migrator.
+func Init(cfg config.Database, logger *slog.Logger) (*gorm.DB, error) {
+	db, err := Open(cfg.DSN, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trace queries run with a context, without recording their arguments
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutQueryVariables(), tracing.WithoutMetrics())); err != nil {
+		return nil, fmt.Errorf("failed to set up database tracing: %w", err)
+	}
+
+	// Expose connection pool stats alongside the other metrics
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := prometheus.Register(collectors.NewDBStatsCollector(sqlDB, "orders")); err != nil {
+		return nil, fmt.Errorf("failed to register database metrics: %w", err)
+	}
+
+	return db, nil
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Open connects to the database of dsn, without instrumentation. The driver
+// is chosen by the scheme: postgres:// or postgresql://, or a key=value
+// connection string, for PostgreSQL, and sqlite://<path> for SQLite, where
+// sqlite://:memory: is a database private to the connection.
+func Open(dsn string, logger *slog.Logger) (*gorm.DB, error) {
+	dialector, err := dialector(dsn)
 	if err != nil {
-		log.Fatal("failed to connect to database:", err)
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: gormlogger.NewSlogLogger(logger, gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+		// Report constraint violations as gorm.ErrDuplicatedKey and
+		// gorm.ErrForeignKeyViolated
+		TranslateError: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if dialector.Name() == "sqlite" {
+		// SQLite serializes writes anyway, and an in-memory database only
+		// lives as long as its connection
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
 	}
 
-	db.AutoMigrate(&models.Order{}, &localModels.Customer{}, &localModels.Product{}, &localModels.CustomerOrder{}, &localModels.OrderProduct{})
+	return db, nil
+}
 
-	return db
+// dialector returns the gorm driver for dsn
+func dialector(dsn string) (gorm.Dialector, error) {
+	scheme, rest, ok := strings.Cut(dsn, "://")
+	if !ok {
+		// key=value connection strings are only understood by PostgreSQL
+		return postgres.Open(dsn), nil
+	}
+
+	switch scheme {
+	case "postgres", "postgresql":
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		if rest == "" {
+			return nil, errors.New("sqlite database path is missing, use sqlite://<path> or sqlite://:memory:")
+		}
+		// Enforce foreign keys like PostgreSQL does, SQLite doesn't by default
+		separator := "?"
+		if strings.Contains(rest, "?") {
+			separator = "&"
+		}
+		return sqlite.Open(rest + separator + "_pragma=foreign_keys(1)"), nil
+	}
+	return nil, fmt.Errorf("unsupported database scheme %q, use postgres:// or sqlite://", scheme)
 }
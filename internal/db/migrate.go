@@ -0,0 +1,336 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MigrationsDir is where migrations live in the source tree, relative to
+// the repository root
+const MigrationsDir = "internal/db/migrations"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationFile matches <version>_<name>[.<dialect>].<up|down>.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)(?:\.([a-z]+))?\.(up|down)\.sql$`)
+
+// ErrSchemaBehind is returned when migrations are waiting to be applied
+var ErrSchemaBehind = errors.New("database schema is behind, run migrate up")
+
+// Migration is a versioned change to the schema and the SQL to revert it.
+// Migrations are written to run on every supported database, except for
+// the statements overridden in Dialects.
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+	// Dialects holds the SQL replacing Up or Down on a database, by gorm
+	// dialect name, e.g. sqlite for 0001_initial_schema.sqlite.up.sql
+	Dialects map[string]MigrationSQL
+}
+
+// MigrationSQL is the SQL of a migration specific to a database. Empty
+// fields fall back to the portable SQL.
+type MigrationSQL struct {
+	Up   string
+	Down string
+}
+
+// SQL returns the SQL applying and reverting the migration on dialect
+func (m Migration) SQL(dialect string) (up, down string) {
+	override := m.Dialects[dialect]
+	return cmp.Or(override.Up, m.Up), cmp.Or(override.Down, m.Down)
+}
+
+// MigrationStatus tells whether a migration was applied
+type MigrationStatus struct {
+	Version uint64
+	Name    string
+	// AppliedAt is nil for pending migrations
+	AppliedAt *time.Time
+	// Unknown is set for applied migrations this binary doesn't have
+	Unknown bool
+}
+
+// Migrations returns the migrations embedded in the binary, by version
+func Migrations() ([]Migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return LoadMigrations(sub)
+}
+
+// LoadMigrations reads the migrations at the root of fsys, by version.
+// Every migration needs both a portable up and down file, dialect specific
+// files are optional.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[uint64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+
+		sql, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+
+		dialect, up := match[3], match[4] == "up"
+		if dialect == "" {
+			if up {
+				m.Up = string(sql)
+			} else {
+				m.Down = string(sql)
+			}
+			continue
+		}
+
+		if m.Dialects == nil {
+			m.Dialects = map[string]MigrationSQL{}
+		}
+		override := m.Dialects[dialect]
+		if up {
+			override.Up = string(sql)
+		} else {
+			override.Down = string(sql)
+		}
+		m.Dialects[dialect] = override
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" || strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+
+	return migrations, nil
+}
+
+// CreateMigration writes empty up and down files for a new migration in dir,
+// numbered after the last one, and returns their paths
+func CreateMigration(dir, name string) ([]string, error) {
+	name = strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return nil, errors.New("migration name is required")
+	}
+
+	existing, err := LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+	var version uint64 = 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	var paths []string
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s (%s)\n", strings.ReplaceAll(name, "_", " "), direction)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to create migration: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Migrator applies and reverts migrations, recording the applied ones in
+// the schema_migrations table. Each migration runs in its own transaction.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for migrations, sorted by version
+func NewMigrator(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   uint64
+	Name      string
+	AppliedAt time.Time
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	// SQLite drivers only read times back from DATETIME columns
+	timestamp := "TIMESTAMPTZ"
+	if m.db.Dialector.Name() == "sqlite" {
+		timestamp = "DATETIME"
+	}
+	err := m.db.WithContext(ctx).Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    BIGINT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    applied_at ` + timestamp + ` NOT NULL
+)`).Error
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// applied returns the applied migrations by version
+func (m *Migrator) applied(ctx context.Context) ([]appliedMigration, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var rows []appliedMigration
+	err := m.db.WithContext(ctx).
+		Raw("SELECT version, name, applied_at FROM schema_migrations ORDER BY version").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return rows, nil
+}
+
+// Pending returns the migrations that haven't been applied, by version
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !slices.ContainsFunc(applied, func(a appliedMigration) bool { return a.Version == migration.Version }) {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Check returns ErrSchemaBehind when migrations are pending
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending, next is %d_%s", ErrSchemaBehind, len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// Up applies the pending migrations in order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, migration := range pending {
+		up, _ := migration.SQL(m.db.Dialector.Name())
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(up).Error; err != nil {
+				return err
+			}
+			return tx.Exec(
+				"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+				migration.Version, migration.Name, time.Now().UTC(),
+			).Error
+		})
+		if err != nil {
+			return pending[:i], fmt.Errorf("failed to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// Down reverts the last n applied migrations, newest first, and returns them
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(applied) - 1; i >= 0 && len(reverted) < n; i-- {
+		idx := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == applied[i].Version })
+		if idx < 0 {
+			return reverted, fmt.Errorf("migration %d_%s is not known to this binary", applied[i].Version, applied[i].Name)
+		}
+		migration := m.migrations[idx]
+
+		_, down := migration.SQL(m.db.Dialector.Name())
+		err := m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(down).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("failed to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		reverted = append(reverted, migration)
+	}
+	return reverted, nil
+}
+
+// Status lists the known migrations and the applied ones this binary
+// doesn't have, by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if idx := slices.IndexFunc(applied, func(a appliedMigration) bool { return a.Version == migration.Version }); idx >= 0 {
+			status.AppliedAt = &applied[idx].AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, a := range applied {
+		if !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == a.Version }) {
+			statuses = append(statuses, MigrationStatus{Version: a.Version, Name: a.Name, AppliedAt: &a.AppliedAt, Unknown: true})
+		}
+	}
+	slices.SortFunc(statuses, func(a, b MigrationStatus) int { return cmp.Compare(a.Version, b.Version) })
+
+	return statuses, nil
+}
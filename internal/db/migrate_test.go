@@ -0,0 +1,218 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	dbMock, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: dbMock,
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open gorm DB: %v", err)
+	}
+
+	return gormDB, mock
+}
+
+var testMigrations = []db.Migration{
+	{Version: 1, Name: "create_orders", Up: "CREATE TABLE orders (id BIGINT)", Down: "DROP TABLE orders"},
+	{Version: 2, Name: "add_status", Up: "ALTER TABLE orders ADD status TEXT", Down: "ALTER TABLE orders DROP status"},
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatalf("failed to load embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 {
+		t.Errorf("expected migrations starting at version 1, got %v", migrations)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := db.LoadMigrations(fstest.MapFS{
+		"0002_add_status.up.sql":           {Data: []byte("ALTER TABLE orders ADD status TEXT")},
+		"0002_add_status.down.sql":         {Data: []byte("ALTER TABLE orders DROP status")},
+		"0001_create_orders.up.sql":        {Data: []byte("CREATE TABLE orders (id BIGINT)")},
+		"0001_create_orders.down.sql":      {Data: []byte("DROP TABLE orders")},
+		"0001_create_orders.sqlite.up.sql": {Data: []byte("CREATE TABLE orders (id INTEGER)")},
+		"README.md":                        {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatalf("expected migrations to load, got %v", err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Name != "create_orders" || migrations[1].Version != 2 {
+		t.Errorf("expected migrations sorted by version, got %v", migrations)
+	}
+
+	up, down := migrations[0].SQL("sqlite")
+	if up != "CREATE TABLE orders (id INTEGER)" || down != "DROP TABLE orders" {
+		t.Errorf("expected the sqlite up file and the portable down file, got %q and %q", up, down)
+	}
+	if up, _ := migrations[0].SQL("postgres"); up != "CREATE TABLE orders (id BIGINT)" {
+		t.Errorf("expected the portable up file, got %q", up)
+	}
+
+	_, err = db.LoadMigrations(fstest.MapFS{
+		"0001_create_orders.up.sql": {Data: []byte("CREATE TABLE orders (id BIGINT)")},
+	})
+	if err == nil {
+		t.Error("expected an error for a migration without a down file")
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(dir+"/0007_existing.up.sql", []byte("SELECT 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/0007_existing.down.sql", []byte("SELECT 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := db.CreateMigration(dir, "Add order status")
+	if err != nil {
+		t.Fatalf("expected migration to be created, got %v", err)
+	}
+
+	expected := []string{dir + "/0008_add_order_status.up.sql", dir + "/0008_add_order_status.down.sql"}
+	if len(paths) != 2 || paths[0] != expected[0] || paths[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, paths)
+	}
+}
+
+func TestMigratorUp(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, name, applied_at FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).AddRow(1, "create_orders", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE orders ADD status TEXT")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO schema_migrations")).
+		WithArgs(2, "add_status", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	applied, err := db.NewMigrator(gormDB, testMigrations).Up(context.Background())
+	if err != nil {
+		t.Fatalf("expected pending migration to apply, got %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("expected migration 2 to be applied, got %v", applied)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled sqlmock expectations: %v", err)
+	}
+}
+
+func TestMigratorCheck(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, name, applied_at FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}))
+
+	err := db.NewMigrator(gormDB, testMigrations).Check(context.Background())
+	if !errors.Is(err, db.ErrSchemaBehind) {
+		t.Errorf("expected ErrSchemaBehind, got %v", err)
+	}
+}
+
+func TestMigratorDown(t *testing.T) {
+	gormDB, mock := setupMockDB(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS schema_migrations")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version, name, applied_at FROM schema_migrations")).
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "applied_at"}).
+			AddRow(1, "create_orders", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)).
+			AddRow(2, "add_status", time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("ALTER TABLE orders DROP status")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM schema_migrations WHERE version = $1")).
+		WithArgs(2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	reverted, err := db.NewMigrator(gormDB, testMigrations).Down(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("expected last migration to revert, got %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != 2 {
+		t.Errorf("expected migration 2 to be reverted, got %v", reverted)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled sqlmock expectations: %v", err)
+	}
+}
+
+func TestMigrateSQLite(t *testing.T) {
+	gormDB, err := db.Open("sqlite://:memory:", slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("failed to open SQLite: %v", err)
+	}
+	migrations, err := db.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator := db.NewMigrator(gormDB, migrations)
+	ctx := context.Background()
+
+	// Every migration applies and reverts cleanly, twice
+	for range 2 {
+		if _, err := migrator.Up(ctx); err != nil {
+			t.Fatalf("expected migrations to apply, got %v", err)
+		}
+		if err := migrator.Check(ctx); err != nil {
+			t.Errorf("expected no pending migration, got %v", err)
+		}
+		if _, err := migrator.Down(ctx, len(migrations)); err != nil {
+			t.Fatalf("expected migrations to revert, got %v", err)
+		}
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("expected migration %d to be applied", status.Version)
+		}
+	}
+}
+
+func TestOpenUnsupportedScheme(t *testing.T) {
+	if _, err := db.Open("mysql://localhost/orders", slog.New(slog.DiscardHandler)); err == nil {
+		t.Error("expected an error for an unsupported scheme")
+	}
+}
@@ -0,0 +1,42 @@
+-- SQLite has no BIGSERIAL, AUTOINCREMENT keeps IDs from being reused like
+-- Postgres sequences do.
+CREATE TABLE IF NOT EXISTS customers (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_customers_deleted_at ON customers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS products (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at  DATETIME,
+    updated_at  DATETIME,
+    deleted_at  DATETIME,
+    customer_id BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS customer_orders (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id BIGINT,
+    order_id    BIGINT,
+    CONSTRAINT fk_customer_orders_customer FOREIGN KEY (customer_id) REFERENCES customers (id),
+    CONSTRAINT fk_customer_orders_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE TABLE IF NOT EXISTS order_products (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id   BIGINT,
+    product_id BIGINT,
+    CONSTRAINT fk_order_products_order FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_order_products_product FOREIGN KEY (product_id) REFERENCES products (id)
+);
@@ -0,0 +1,42 @@
+-- Schema previously created by gorm's AutoMigrate. IF NOT EXISTS lets
+-- databases set up that way adopt the migrations as they are.
+CREATE TABLE IF NOT EXISTS customers (
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_customers_deleted_at ON customers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS products (
+    id         BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products (deleted_at);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id          BIGSERIAL PRIMARY KEY,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    deleted_at  TIMESTAMPTZ,
+    customer_id BIGINT
+);
+CREATE INDEX IF NOT EXISTS idx_orders_deleted_at ON orders (deleted_at);
+
+CREATE TABLE IF NOT EXISTS customer_orders (
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT,
+    order_id    BIGINT,
+    CONSTRAINT fk_customer_orders_customer FOREIGN KEY (customer_id) REFERENCES customers (id),
+    CONSTRAINT fk_customer_orders_order FOREIGN KEY (order_id) REFERENCES orders (id)
+);
+
+CREATE TABLE IF NOT EXISTS order_products (
+    id         BIGSERIAL PRIMARY KEY,
+    order_id   BIGINT,
+    product_id BIGINT,
+    CONSTRAINT fk_order_products_order FOREIGN KEY (order_id) REFERENCES orders (id),
+    CONSTRAINT fk_order_products_product FOREIGN KEY (product_id) REFERENCES products (id)
+);
@@ -0,0 +1,2 @@
+-- Existing lines were one unit each
+ALTER TABLE order_products ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1;
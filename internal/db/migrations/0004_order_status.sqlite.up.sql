@@ -0,0 +1,5 @@
+-- SQLite drivers only read times back from DATETIME columns
+ALTER TABLE orders ADD COLUMN status TEXT NOT NULL DEFAULT 'confirmed';
+ALTER TABLE orders ADD COLUMN cancel_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN cancel_note TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders ADD COLUMN cancelled_at DATETIME;
@@ -0,0 +1,2 @@
+DROP INDEX idx_orders_status_reservation;
+ALTER TABLE orders DROP COLUMN reservation_expires_at;
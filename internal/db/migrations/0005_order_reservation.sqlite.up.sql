@@ -0,0 +1,3 @@
+-- SQLite drivers only read times back from DATETIME columns
+ALTER TABLE orders ADD COLUMN reservation_expires_at DATETIME;
+CREATE INDEX idx_orders_status_reservation ON orders (status, reservation_expires_at);
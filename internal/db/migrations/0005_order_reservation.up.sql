@@ -0,0 +1,4 @@
+-- Deadline of the stock reservation of orders pending stock, past which
+-- they are cancelled
+ALTER TABLE orders ADD COLUMN reservation_expires_at TIMESTAMPTZ;
+CREATE INDEX idx_orders_status_reservation ON orders (status, reservation_expires_at);
@@ -1,26 +1,88 @@
 package dto
 
-import "github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+import (
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+)
+
+type OrdersInput struct {
+	IncludeDeleted bool `query:"includeDeleted" doc:"Include deleted orders, for admins only"`
+}
 
 type OrdersOutput struct {
 	Body struct {
-		Orders []models.Order `json:"orders"`
+		Orders []localModels.Order `json:"orders"`
 	}
 }
 
 type OrderOutput struct {
-	Body models.Order
+	Body localModels.Order
 }
 
 type OrderCreateInput struct {
-	Body struct {
-		CustomerID uint   `json:"customerId"`
-		ProductIDs []uint `json:"productIds"`
-	}
+	Body OrderCreateInputBody
+}
+
+// OrderCreateInputBody describes an order. Its products are given either as
+// lines with a quantity or as productIds, one unit each. The maximum number
+// of lines and total quantity are configured.
+type OrderCreateInputBody struct {
+	CustomerID uint             `json:"customerId" minimum:"1" doc:"ID of the customer placing the order"`
+	Lines      []OrderLineInput `json:"lines,omitempty" minItems:"1" doc:"Products of the order with their quantity, each product at most once"`
+	ProductIDs []uint           `json:"productIds,omitempty" minItems:"1" uniqueItems:"true" doc:"Products of the order, one unit each, instead of lines"`
+}
+
+// OrderLineInput is a product of an order and the number of units ordered
+type OrderLineInput struct {
+	ProductID uint `json:"productId" minimum:"1" doc:"ID of the product"`
+	Quantity  int  `json:"quantity" required:"false" minimum:"1" default:"1" doc:"Number of units ordered"`
+}
+
+type OrderUpdateInput struct {
+	Id   uint `path:"id"`
+	Body OrderUpdateInputBody
+}
+
+// OrderUpdateInputBody changes the customer of an order, its lines can't be
+// changed. Other fields, such as the productIds older clients still send,
+// are ignored.
+type OrderUpdateInputBody struct {
+	_          struct{} `json:"-" additionalProperties:"true"`
+	CustomerID uint     `json:"customerId" minimum:"1" doc:"ID of the customer the order belongs to"`
+}
+
+type OrderCancelInput struct {
+	Id   uint `path:"id"`
+	Body OrderCancelInputBody
+}
+
+// OrderCancelInputBody tells why an order is cancelled
+type OrderCancelInputBody struct {
+	Reason localModels.CancelReason `json:"reason" enum:"customer_request,out_of_stock,payment_failed,duplicate,fraud_suspected,other" doc:"Why the order is cancelled"`
+	Note   string                   `json:"note,omitempty" maxLength:"500" doc:"Free text explaining the cancellation"`
+}
+
+type OrderInput struct {
+	Id             uint `path:"id"`
+	IncludeDeleted bool `query:"includeDeleted" doc:"Include deleted orders, for admins only"`
 }
 
 type CustomerOrdersInput struct {
-	CustomerID uint `json:"customerId" path:"customerId"`
+	CustomerID     uint `json:"customerId" path:"customerId"`
+	IncludeDeleted bool `query:"includeDeleted" doc:"Include deleted orders, for admins only"`
+}
+
+type OrdersPurgeInput struct {
+	OlderThan string `query:"olderThan" doc:"Purge the orders deleted for longer than this duration, e.g. 720h, instead of the configured retention"`
+}
+
+type OrdersPurgeOutput struct {
+	Body struct {
+		Purged        int64     `json:"purged" doc:"Number of orders removed for good"`
+		DeletedBefore time.Time `json:"deletedBefore" doc:"Orders deleted before this time were removed"`
+	}
 }
 
 type ProductsOutputBody struct {
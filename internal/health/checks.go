@@ -0,0 +1,33 @@
+package health
+
+import (
+	"context"
+	"errors"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+)
+
+// Database pings the database behind the gorm connection
+func Database(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// RabbitMQ checks that the AMQP connection and channel are open
+func RabbitMQ(conn *amqp.Connection, ch *amqp.Channel) Check {
+	return func(ctx context.Context) error {
+		if conn.IsClosed() {
+			return errors.New("connection closed")
+		}
+		if ch.IsClosed() {
+			return errors.New("channel closed")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,57 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/health"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	checker := health.NewChecker(20 * time.Millisecond)
+	checker.Add("database", func(ctx context.Context) error { return nil })
+	checker.Add("rabbitmq", func(ctx context.Context) error { return errors.New("connection closed") })
+	checker.Add("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", rec.Code)
+	}
+
+	var report health.Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+
+	if report.Checks["database"].Status != health.StatusOK {
+		t.Errorf("expected database ok, got %+v", report.Checks["database"])
+	}
+	if report.Checks["rabbitmq"].Error != "connection closed" {
+		t.Errorf("expected rabbitmq failure, got %+v", report.Checks["rabbitmq"])
+	}
+	if report.Checks["slow"].Status != health.StatusFail {
+		t.Errorf("expected slow check to time out, got %+v", report.Checks["slow"])
+	}
+}
+
+func TestReadinessHandlerHealthy(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	checker.Add("database", func(ctx context.Context) error { return nil })
+
+	rec := httptest.NewRecorder()
+	checker.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+)
+
+// Output formats accepted by New
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options configures the service logger
+type Options struct {
+	// Level is one of debug, info, warn or error
+	Level string
+	// Format is json or text
+	Format string
+}
+
+// New creates a logger writing to w. Records logged with a context get the
+// correlation ID and the fields added with With.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	var level slog.Level
+	if opts.Level != "" {
+		if err := level.UnmarshalText([]byte(opts.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q", opts.Level)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, handlerOpts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", opts.Format)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+type contextKey string
+
+const attrsKey contextKey = "logging/attrs"
+
+// With returns a copy of ctx whose log records carry the given attributes,
+// e.g. the order or customer being processed
+func With(ctx context.Context, args ...any) context.Context {
+	record := slog.Record{}
+	record.Add(args...)
+
+	attrs := append([]slog.Attr{}, attrsFrom(ctx)...)
+	record.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	return context.WithValue(ctx, attrsKey, attrs)
+}
+
+func attrsFrom(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler adds the request ID and context attributes to records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := correlation.ID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	r.AddAttrs(attrsFrom(ctx)...)
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,48 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+)
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Options{Level: "debug", Format: "json"})
+	if err != nil {
+		t.Fatalf("failed to create logger: %v", err)
+	}
+
+	ctx := correlation.WithID(context.Background(), "req-1")
+	ctx = logging.With(ctx, "order_id", 12)
+	ctx = logging.With(ctx, "customer_id", 3)
+	logger.WarnContext(ctx, "Failed to publish order event")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a JSON record, got %q", buf.String())
+	}
+
+	if record["request_id"] != "req-1" {
+		t.Errorf("expected request_id req-1, got %v", record["request_id"])
+	}
+	if record["order_id"] != float64(12) || record["customer_id"] != float64(3) {
+		t.Errorf("expected order and customer IDs, got %v", record)
+	}
+	if record["level"] != "WARN" {
+		t.Errorf("expected WARN level, got %v", record["level"])
+	}
+}
+
+func TestInvalidOptions(t *testing.T) {
+	if _, err := logging.New(&bytes.Buffer{}, logging.Options{Level: "loud"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if _, err := logging.New(&bytes.Buffer{}, logging.Options{Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
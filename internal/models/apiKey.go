@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// APIKey is an API key of a service. Only the hash of the key is stored.
+type APIKey struct {
+	ID   string `gorm:"primaryKey"`
+	Name string
+	Hash string
+	// Scopes are space separated
+	Scopes     string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
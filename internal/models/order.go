@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+)
+
+// OrderStatus is where an order stands in its lifecycle
+type OrderStatus string
+
+const (
+	// OrderPendingStock orders wait for the Products service to reserve
+	// their stock
+	OrderPendingStock OrderStatus = "pending_stock"
+	OrderConfirmed    OrderStatus = "confirmed"
+	OrderCancelled    OrderStatus = "cancelled"
+)
+
+// CancelReason tells why an order was cancelled
+type CancelReason string
+
+const (
+	CancelCustomerRequest CancelReason = "customer_request"
+	CancelOutOfStock      CancelReason = "out_of_stock"
+	CancelPaymentFailed   CancelReason = "payment_failed"
+	CancelDuplicate       CancelReason = "duplicate"
+	CancelFraudSuspected  CancelReason = "fraud_suspected"
+	CancelOther           CancelReason = "other"
+	// CancelReservationExpired orders weren't reserved in time
+	CancelReservationExpired CancelReason = "reservation_expired"
+)
+
+// CancellableStatuses are the statuses an order can be cancelled from
+var CancellableStatuses = []OrderStatus{OrderPendingStock, OrderConfirmed}
+
+// EditableStatuses are the statuses in which the customer of an order can
+// be changed
+var EditableStatuses = []OrderStatus{OrderPendingStock, OrderConfirmed}
+
+// Order is the order shared with the other services along with its status.
+// Cancelled orders stay visible, unlike deleted ones.
+type Order struct {
+	models.Order
+	Status       OrderStatus  `json:"status" enum:"pending_stock,confirmed,cancelled" doc:"Status of the order"`
+	CancelReason CancelReason `json:"cancelReason,omitempty" enum:"customer_request,out_of_stock,payment_failed,duplicate,fraud_suspected,other,reservation_expired" doc:"Why the order was cancelled"`
+	CancelNote   string       `json:"cancelNote,omitempty" doc:"Note left when cancelling the order"`
+	CancelledAt  *time.Time   `json:"cancelledAt,omitempty" doc:"When the order was cancelled"`
+	// ReservationExpiresAt is when an order pending stock is cancelled
+	// unless its stock was reserved
+	ReservationExpiresAt *time.Time `json:"reservationExpiresAt,omitempty" doc:"When the order is cancelled unless its stock is reserved by then"`
+}
@@ -8,4 +8,5 @@ type OrderProduct struct {
 	Order     models.Order `gorm:"foreignKey:OrderID"`
 	ProductID uint         `json:"productId"`
 	Product   Product      `gorm:"foreignKey:ProductID"`
+	Quantity  int          `json:"quantity"`
 }
@@ -0,0 +1,66 @@
+package operation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/problem"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// injectableEvents are the routing key prefixes of the events other services
+// would send us
+var injectableEvents = []string{"customer.", "product."}
+
+// EventInput is an event to deliver on the in-process bus. The body is sent
+// as is, so both legacy JSON events and structured CloudEvents are accepted.
+type EventInput struct {
+	RoutingKey  string `path:"routingKey" doc:"Routing key of the event, e.g. customer.created" example:"customer.created"`
+	ContentType string `header:"Content-Type" default:"application/json"`
+	RawBody     []byte
+}
+
+// RegisterEventRoutes registers the endpoint injecting customer and product
+// events into bus, standing in for the other services when running without
+// RabbitMQ
+func RegisterEventRoutes(api huma.API, bus *rabbitmq.MemoryBus) {
+	huma.Register(api, huma.Operation{
+		OperationID:   "inject-event",
+		Summary:       "Inject an event into the in-process bus",
+		Description:   "Only available with the memory broker. The event is handled before the response is sent, handler errors are logged.",
+		Method:        http.MethodPost,
+		DefaultStatus: http.StatusAccepted,
+		Path:          "/events/{routingKey}",
+		Tags:          []string{"events"},
+		Security:      adminAccess,
+	}, func(ctx context.Context, input *EventInput) (*struct{}, error) {
+		if !injectable(input.RoutingKey) {
+			return nil, problem.New(problem.EventNotInjectable, input.RoutingKey)
+		}
+
+		bus.Publish(ctx, message.Message{
+			RoutingKey:    input.RoutingKey,
+			Body:          input.RawBody,
+			ContentType:   input.ContentType,
+			MessageID:     message.NewID(),
+			CorrelationID: correlation.ID(ctx),
+			Timestamp:     time.Now().UTC(),
+		})
+
+		return &struct{}{}, nil
+	})
+}
+
+func injectable(routingKey string) bool {
+	for _, prefix := range injectableEvents {
+		if name, ok := strings.CutPrefix(routingKey, prefix); ok && name != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package operation_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/operation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/saga"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+func TestInjectEvent(t *testing.T) {
+	registry, err := schema.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+	repos := repository.NewMemory()
+	bus := rabbitmq.NewMemoryBus("events", logger)
+	bus.Subscribe(rabbitmq.SetupEventHandlers(repos, saga.NewReservations(repos.Orders, bus, logger), registry, time.Second, logger))
+
+	_, api := humatest.New(t)
+	operation.RegisterEventRoutes(api, bus)
+
+	resp := api.Post("/events/customer.created", strings.NewReader(`{"type":"customer.created","customer":{"ID":3}}`))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if !repos.Customers.(*repository.MemoryCustomerRepository).Exists(3) {
+		t.Error("expected the injected event to create customer 3")
+	}
+
+	// The stock reservation saga confirms pending orders
+	order := localModels.Order{Order: models.Order{CustomerID: 3}, Status: localModels.OrderPendingStock}
+	if err := repos.Orders.Create(context.Background(), &order, nil); err != nil {
+		t.Fatal(err)
+	}
+	resp = api.Post("/events/product.stock.reserved", strings.NewReader(`{"type":"product.stock.reserved","orderId":1}`))
+	if resp.Code != http.StatusAccepted {
+		t.Fatalf("expected status 202, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if order, _ := repos.Orders.Get(context.Background(), 1); order.Status != localModels.OrderConfirmed {
+		t.Errorf("expected the injected event to confirm order 1, got %q", order.Status)
+	}
+
+	resp = api.Post("/events/order.created", strings.NewReader(`{}`))
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an order event, got %d", resp.Code)
+	}
+}
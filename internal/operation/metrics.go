@@ -0,0 +1,42 @@
+package operation
+
+import (
+	"github.com/go-chi/metrics"
+)
+
+// There is no outbox, events are published when orders are written, so no
+// backlog is reported
+var (
+	ordersCounter       = metrics.CounterWith[orderLabels]("orders_total", "Total number of orders written, by operation.")
+	orderLinesHistogram = metrics.Histogram(
+		"order_lines",
+		"Number of product lines per created order.",
+		[]float64{1, 2, 3, 5, 10, 20, 50, 100},
+	)
+	orderValueHistogram = metrics.Histogram(
+		"order_value",
+		"Value of each created order at the prices of the Products service.",
+		[]float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000},
+	)
+	productsAPIHistogram = metrics.HistogramWith[productsAPILabels](
+		"products_api_request_duration_seconds",
+		"Latency of requests to the Products service in seconds.",
+		[]float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	)
+	productsAPIErrorsCounter = metrics.CounterWith[productsAPIErrorLabels]("products_api_errors_total", "Total number of failed requests to the Products service, by reason.")
+)
+
+// orderLabels defines labels for the orders counter
+type orderLabels struct {
+	Operation string `label:"operation"`
+}
+
+// productsAPILabels defines labels for the Products service latency histogram
+type productsAPILabels struct {
+	Status string `label:"status"`
+}
+
+// productsAPIErrorLabels defines labels for the Products service errors counter
+type productsAPIErrorLabels struct {
+	Reason string `label:"reason"`
+}
@@ -2,22 +2,26 @@ package operation
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
 	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
 	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/dto"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
 	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/policy"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/problem"
 	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
 	"github.com/danielgtaylor/huma/v2"
-	amqp "github.com/rabbitmq/amqp091-go"
-	"gorm.io/gorm"
 )
 
 // ----------------------
@@ -25,93 +29,65 @@ import (
 // ----------------------
 
 // Get all orders
-func GetOrders(ctx context.Context, db *gorm.DB) (*dto.OrdersOutput, error) {
+func GetOrders(ctx context.Context, orderRepo repository.OrderRepository) (*dto.OrdersOutput, error) {
 	resp := &dto.OrdersOutput{}
 
-	var orders []models.Order
-	results := db.Find(&orders)
-
-	if results.Error == nil {
+	orders, err := orderRepo.List(ctx)
+	if err == nil {
 		resp.Body.Orders = orders
 	}
 
-	return resp, results.Error
+	return resp, err
 }
 
 // Get a single order by ID
-func GetOrder(ctx context.Context, db *gorm.DB, id uint) (*dto.OrderOutput, error) {
+func GetOrder(ctx context.Context, orderRepo repository.OrderRepository, productsClient *ProductsClient, logger *slog.Logger, id uint) (*dto.OrderOutput, error) {
 	resp := &dto.OrderOutput{}
+	ctx = logging.With(ctx, "order_id", id)
 
-	var order models.Order
-	results := db.First(&order, id)
-
-	if results.Error != nil {
-		if errors.Is(results.Error, gorm.ErrRecordNotFound) {
-			return nil, huma.NewError(http.StatusNotFound, "Order not found")
-		}
-		return nil, results.Error
+	order, err := orderRepo.Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, problem.New(problem.OrderNotFound, id)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	resp.Body = order
 
-	products_url := os.Getenv("PRODUCTS_URL")
-	url := fmt.Sprintf("%s/products/%d/orders", products_url, order.ID)
-	r, err := http.Get(url)
+	products, err := productsClient.OrderProducts(ctx, order.ID)
 	if err != nil {
-		fmt.Printf("Failed to fetch products: %v\n", err)
+		logger.WarnContext(ctx, "Failed to fetch products", "error", err)
 		return resp, nil
 	}
-	defer r.Body.Close()
-
-	if r.StatusCode == http.StatusOK {
-		var productsResp dto.ProductsOutputBody
-		if err := json.NewDecoder(r.Body).Decode(&productsResp); err != nil {
-			fmt.Printf("Failed to decode products response: %v\n", err)
-		} else {
-			resp.Body.Products = productsResp.Products
-		}
-	} else {
-		fmt.Printf("Products API returned status %d\n", r.StatusCode)
-	}
+	resp.Body.Products = products
 
 	return resp, nil
 }
 
-func GetOrdersByIdCustomer(ctx context.Context, db *gorm.DB, id uint) (*dto.OrdersOutput, error) {
+func GetOrdersByIdCustomer(ctx context.Context, orderRepo repository.OrderRepository, productsClient *ProductsClient, logger *slog.Logger, id uint) (*dto.OrdersOutput, error) {
 	resp := &dto.OrdersOutput{}
+	ctx = logging.With(ctx, "customer_id", id)
 
-	var orders []models.Order
-	if err := db.Where("customer_id = ?", id).Find(&orders).Error; err != nil {
+	orders, err := orderRepo.ListByCustomer(ctx, id)
+	if err != nil {
 		return nil, err
 	}
 
-	productsURL := os.Getenv("PRODUCTS_URL")
-	client := &http.Client{Timeout: 5 * time.Second}
 	var wg sync.WaitGroup
 
 	for i := range orders {
 		wg.Add(1)
-		go func(order *models.Order) {
+		go func(order *localModels.Order) {
 			defer wg.Done()
+			ctx := logging.With(ctx, "order_id", order.ID)
 
-			url := fmt.Sprintf("%s/products/%d/orders", productsURL, order.ID)
-			r, err := client.Get(url)
+			products, err := productsClient.OrderProducts(ctx, order.ID)
 			if err != nil {
-				fmt.Printf("Failed to fetch products for order %d: %v\n", order.ID, err)
+				logger.WarnContext(ctx, "Failed to fetch products", "error", err)
 				return
 			}
-			defer r.Body.Close()
-
-			if r.StatusCode == http.StatusOK {
-				var productsResp dto.ProductsOutputBody
-				if err := json.NewDecoder(r.Body).Decode(&productsResp); err != nil {
-					fmt.Printf("Failed to decode products for order %d: %v\n", order.ID, err)
-					return
-				}
-				order.Products = productsResp.Products
-			} else {
-				fmt.Printf("Products API for order %d returned status %d\n", order.ID, r.StatusCode)
-			}
+			order.Products = products
 		}(&orders[i])
 	}
 
@@ -126,7 +102,124 @@ func GetOrdersByIdCustomer(ctx context.Context, db *gorm.DB, id uint) (*dto.Orde
 // Register routes with Huma
 // ----------------------
 
-func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
+// Who may read and write orders. Customers only read their own.
+var (
+	readAccess = auth.Require(
+		[]auth.Role{auth.RoleCustomer, auth.RoleSupport, auth.RoleAdmin},
+		[]auth.Scope{auth.ScopeOrdersRead, auth.ScopeAdmin},
+	)
+	writeAccess = auth.Require(
+		[]auth.Role{auth.RoleSupport, auth.RoleAdmin},
+		[]auth.Scope{auth.ScopeOrdersWrite, auth.ScopeAdmin},
+	)
+	adminAccess = auth.Require([]auth.Role{auth.RoleAdmin}, []auth.Scope{auth.ScopeAdmin})
+)
+
+// includeDeleted returns ctx reading deleted orders as well when include is
+// set, which only admins may do
+func includeDeleted(ctx context.Context, include bool) (context.Context, error) {
+	if !include {
+		return ctx, nil
+	}
+	if !policy.IsAdmin(ctx) {
+		return nil, huma.Error403Forbidden("Only admins can include deleted orders")
+	}
+	return repository.IncludeDeleted(ctx), nil
+}
+
+// registerOrderSchema registers the schema of order bodies along with the
+// configured limits, before the operations using it, so they are validated
+// and documented
+func registerOrderSchema(api huma.API, limits config.Orders) {
+	schema := api.OpenAPI().Components.Schemas.Schema(reflect.TypeFor[dto.OrderCreateInputBody](), false, "")
+	for _, name := range []string{"lines", "productIds"} {
+		property := schema.Properties[name]
+		property.MaxItems = &limits.MaxLines
+		property.PrecomputeMessages()
+	}
+	line := api.OpenAPI().Components.Schemas.Schema(reflect.TypeFor[dto.OrderLineInput](), false, "")
+	maxQuantity := float64(limits.MaxQuantity)
+	line.Properties["quantity"].Maximum = &maxQuantity
+	line.Properties["quantity"].PrecomputeMessages()
+	schema.Description = fmt.Sprintf("An order of at most %d lines and %d units in total. "+
+		"Products are given either as lines or as productIds.", limits.MaxLines, limits.MaxQuantity)
+}
+
+// orderLines returns the lines of an order, or the reasons they are
+// invalid. The schema already checked each value.
+func orderLines(body dto.OrderCreateInputBody, limits config.Orders) ([]repository.OrderLine, []error) {
+	switch {
+	case body.Lines != nil && body.ProductIDs != nil:
+		return nil, []error{&huma.ErrorDetail{Message: "expected either lines or productIds, not both", Location: "body.productIds"}}
+	case body.Lines == nil && body.ProductIDs == nil:
+		return nil, []error{&huma.ErrorDetail{Message: "expected at least one line", Location: "body.lines"}}
+	}
+
+	lines := make([]repository.OrderLine, 0, max(len(body.Lines), len(body.ProductIDs)))
+	for _, productID := range body.ProductIDs {
+		lines = append(lines, repository.OrderLine{ProductID: productID, Quantity: 1})
+	}
+
+	var errs []error
+	seen := map[uint]int{}
+	for i, line := range body.Lines {
+		if first, ok := seen[line.ProductID]; ok {
+			errs = append(errs, &huma.ErrorDetail{
+				Message:  fmt.Sprintf("expected each product once, also on body.lines[%d]", first),
+				Location: fmt.Sprintf("body.lines[%d].productId", i),
+				Value:    line.ProductID,
+			})
+			continue
+		}
+		seen[line.ProductID] = i
+		// Checked before adding them up, so the total can't overflow
+		if line.Quantity > limits.MaxQuantity {
+			errs = append(errs, &huma.ErrorDetail{
+				Message:  fmt.Sprintf("expected a quantity of at most %d", limits.MaxQuantity),
+				Location: fmt.Sprintf("body.lines[%d].quantity", i),
+				Value:    line.Quantity,
+			})
+			continue
+		}
+		lines = append(lines, repository.OrderLine{ProductID: line.ProductID, Quantity: line.Quantity})
+	}
+
+	if len(errs) > 0 {
+		return lines, errs
+	}
+
+	total := 0
+	for _, line := range lines {
+		total += line.Quantity
+	}
+	if total > limits.MaxQuantity {
+		errs = append(errs, &huma.ErrorDetail{
+			Message:  fmt.Sprintf("expected a total quantity of at most %d", limits.MaxQuantity),
+			Location: "body.lines",
+			Value:    total,
+		})
+	}
+
+	return lines, errs
+}
+
+// observeOrderValue records the value of a created order. Its products are
+// priced by the Products service once the order is answered, so a slow or
+// failing service doesn't hold back orders.
+func observeOrderValue(ctx context.Context, productsClient *ProductsClient, lines []repository.OrderLine, logger *slog.Logger) {
+	value, err := productsClient.OrderValue(ctx, lines)
+	if err != nil {
+		logger.WarnContext(ctx, "Failed to price order", "error", err)
+		return
+	}
+	orderValueHistogram.Observe(value)
+}
+
+// RegisterOrdersRoutes registers the orders API. Every route goes through
+// the access policy, customers only see their own orders.
+func RegisterOrdersRoutes(api huma.API, orderRepo repository.OrderRepository, publisher rabbitmq.EventPublisher, productsClient *ProductsClient, cfg config.Orders, logger *slog.Logger) {
+	orderRepo = policy.NewOrders(orderRepo)
+	registerOrderSchema(api, cfg)
 
 	huma.Register(api, huma.Operation{
 		OperationID: "get-orders",
@@ -134,8 +227,13 @@ func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
 		Method:      http.MethodGet,
 		Path:        "/orders",
 		Tags:        []string{"orders"},
-	}, func(ctx context.Context, input *struct{}) (*dto.OrdersOutput, error) {
-		return GetOrders(ctx, dbConn)
+		Security:    readAccess,
+	}, func(ctx context.Context, input *dto.OrdersInput) (*dto.OrdersOutput, error) {
+		ctx, err := includeDeleted(ctx, input.IncludeDeleted)
+		if err != nil {
+			return nil, err
+		}
+		return GetOrders(ctx, orderRepo)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -144,10 +242,13 @@ func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
 		Method:      http.MethodGet,
 		Path:        "/orders/{id}",
 		Tags:        []string{"orders"},
-	}, func(ctx context.Context, input *struct {
-		Id uint `path:"id"`
-	}) (*dto.OrderOutput, error) {
-		return GetOrder(ctx, dbConn, input.Id)
+		Security:    readAccess,
+	}, func(ctx context.Context, input *dto.OrderInput) (*dto.OrderOutput, error) {
+		ctx, err := includeDeleted(ctx, input.IncludeDeleted)
+		if err != nil {
+			return nil, err
+		}
+		return GetOrder(ctx, orderRepo, productsClient, logger, input.Id)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -157,8 +258,13 @@ func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
 		DefaultStatus: http.StatusOK,
 		Path:          "/orders/{customerId}/customers",
 		Tags:          []string{"orders"},
+		Security:      readAccess,
 	}, func(ctx context.Context, input *dto.CustomerOrdersInput) (*dto.OrdersOutput, error) {
-		return GetOrdersByIdCustomer(ctx, dbConn, input.CustomerID)
+		ctx, err := includeDeleted(ctx, input.IncludeDeleted)
+		if err != nil {
+			return nil, err
+		}
+		return GetOrdersByIdCustomer(ctx, orderRepo, productsClient, logger, input.CustomerID)
 	})
 
 	huma.Register(api, huma.Operation{
@@ -168,57 +274,46 @@ func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
 		DefaultStatus: http.StatusCreated,
 		Path:          "/orders",
 		Tags:          []string{"orders"},
+		Security:      writeAccess,
 	}, func(ctx context.Context, input *dto.OrderCreateInput) (*dto.OrderOutput, error) {
 		resp := &dto.OrderOutput{}
 
-		order := models.Order{
-			CustomerID: input.Body.CustomerID,
+		lines, errs := orderLines(input.Body, cfg)
+		if len(errs) > 0 {
+			return nil, huma.Error422UnprocessableEntity("validation failed", errs...)
 		}
 
-		// Create order in the database
-		results := dbConn.Create(&order)
-		if results.Error != nil {
-			return resp, results.Error
+		order := localModels.Order{
+			Order: models.Order{CustomerID: input.Body.CustomerID},
 		}
-
-		// Create CustomerOrder relationship
-		customerOrder := localModels.CustomerOrder{
-			CustomerID: input.Body.CustomerID,
-			OrderID:    order.ID,
+		if cfg.Reservation.Enabled {
+			// The order.created event asks the Products service to reserve
+			// the stock, the reservation saga confirms or cancels the order
+			expiresAt := time.Now().UTC().Add(cfg.Reservation.Timeout)
+			order.Status, order.ReservationExpiresAt = localModels.OrderPendingStock, &expiresAt
 		}
 
-		if err := dbConn.Create(&customerOrder).Error; err != nil {
-			// Log this but don't fail the order creation itself
-			fmt.Printf("Failed to create CustomerOrder record: %v\n", err)
-		}
-
-		var orderProducts []localModels.OrderProduct
-
-		for _, productID := range input.Body.ProductIDs {
-			orderProducts = append(orderProducts, localModels.OrderProduct{
-				OrderID:   order.ID,
-				ProductID: productID,
-			})
-		}
-
-		if err := dbConn.Create(&orderProducts).Error; err != nil {
-			fmt.Printf("Failed to create OrderProduct records: %v\n", err)
+		// Store the order with its customer and product lines. Unknown
+		// customers and products are reported by the problem mapping.
+		if err := orderRepo.Create(ctx, &order, lines); err != nil {
+			return nil, err
 		}
+		ctx = logging.With(ctx, "order_id", order.ID, "customer_id", order.CustomerID)
 
 		// Prepare response
 		resp.Body = order
 
 		// Publish order created event
-		var simplifiedOrder = events.SimplifiedOrder{
-			OrderID:    order.ID,
-			CustomerID: input.Body.CustomerID,
-			ProductIDs: input.Body.ProductIDs,
-		}
-		if err := rabbitmq.PublishOrderEvent(ch, events.OrderCreated, simplifiedOrder); err != nil {
+		if err := publisher.PublishOrderEvent(ctx, events.OrderCreated, rabbitmq.NewEventOrder(order, lines)); err != nil {
 			// Log error but do not fail the request
-			fmt.Printf("Failed to publish order event: %v\n", err)
+			logger.WarnContext(ctx, "Failed to publish order event", "event", events.OrderCreated, "error", err)
 		}
 
+		ordersCounter.Inc(orderLabels{Operation: "created"})
+		orderLinesHistogram.Observe(float64(len(lines)))
+		go observeOrderValue(context.WithoutCancel(ctx), productsClient, lines, logger)
+		logger.InfoContext(ctx, "Order created")
+
 		return resp, nil
 	})
 
@@ -228,46 +323,89 @@ func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
 		Method:      http.MethodPut,
 		Path:        "/orders/{id}",
 		Tags:        []string{"orders"},
-	}, func(ctx context.Context, input *struct {
-		Id uint `path:"id"`
-		dto.OrderCreateInput
-	}) (*dto.OrderOutput, error) {
+		Security:    writeAccess,
+	}, func(ctx context.Context, input *dto.OrderUpdateInput) (*dto.OrderOutput, error) {
 		resp := &dto.OrderOutput{}
+		ctx = logging.With(ctx, "order_id", input.Id)
 
-		var order models.Order
-		results := dbConn.First(&order, input.Id)
-
-		if errors.Is(results.Error, gorm.ErrRecordNotFound) {
-			return nil, huma.NewError(http.StatusNotFound, "Order not found")
-		}
-		if results.Error != nil {
-			return nil, results.Error
+		order, err := orderRepo.UpdateCustomer(ctx, input.Id, input.Body.CustomerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, problem.New(problem.OrderNotFound, input.Id)
 		}
-
-		updates := models.Order{
-			CustomerID: input.Body.CustomerID,
+		if errors.Is(err, repository.ErrInvalidTransition) {
+			return nil, problem.New(problem.InvalidTransition, input.Id, order.Status, "updated")
 		}
-
-		results = dbConn.Model(&order).Updates(updates)
-		if results.Error != nil {
-			return nil, results.Error
+		if err != nil {
+			return nil, err
 		}
-
-		// Get updated order from DB to ensure all fields are correct
-		dbConn.First(&order, order.ID)
 		resp.Body = order
 
 		// Publish order updated event
-		var simplifiedOrder = events.SimplifiedOrder{
+		var simplifiedOrder = rabbitmq.EventOrder{SimplifiedOrder: events.SimplifiedOrder{
 			OrderID:    order.ID,
 			CustomerID: order.CustomerID,
+		}}
+		if err := publisher.PublishOrderEvent(ctx, events.OrderUpdated, simplifiedOrder); err != nil {
+			// Log the error but don't fail the request
+			// The order was already updated in the database
+			logger.WarnContext(ctx, "Failed to publish order event", "event", events.OrderUpdated, "error", err)
+		}
+
+		ordersCounter.Inc(orderLabels{Operation: "updated"})
+		logger.InfoContext(ctx, "Order updated", "customer_id", order.CustomerID)
+
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "cancel-order",
+		Summary:     "Cancel an order",
+		Description: "Cancels a confirmed order. Unlike a deleted order, a cancelled order stays visible along with why it was cancelled. " +
+			"The order.cancelled event carries its lines, so their stock can be released.",
+		Method:   http.MethodPost,
+		Path:     "/orders/{id}/cancel",
+		Tags:     []string{"orders"},
+		Security: writeAccess,
+	}, func(ctx context.Context, input *dto.OrderCancelInput) (*dto.OrderOutput, error) {
+		resp := &dto.OrderOutput{}
+		ctx = logging.With(ctx, "order_id", input.Id)
+
+		// Lines never change, read them first so a failure leaves the
+		// order as is
+		lines, err := orderRepo.Lines(ctx, input.Id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, problem.New(problem.OrderNotFound, input.Id)
 		}
-		err := rabbitmq.PublishOrderEvent(ch, events.OrderUpdated, simplifiedOrder)
 		if err != nil {
+			return nil, err
+		}
+
+		order, err := orderRepo.ChangeStatus(ctx, input.Id, repository.StatusChange{
+			From:         localModels.CancellableStatuses,
+			To:           localModels.OrderCancelled,
+			CancelReason: input.Body.Reason,
+			CancelNote:   input.Body.Note,
+		})
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, problem.New(problem.OrderNotFound, input.Id)
+		case errors.Is(err, repository.ErrInvalidTransition):
+			return nil, problem.New(problem.InvalidTransition, input.Id, order.Status, localModels.OrderCancelled)
+		case err != nil:
+			return nil, err
+		}
+		resp.Body = order
+
+		// Publish order cancelled event
+		if err := publisher.PublishOrderEvent(ctx, rabbitmq.OrderCancelled, rabbitmq.NewEventOrder(order, lines)); err != nil {
 			// Log the error but don't fail the request
-			// The order was already updated in the database
+			// The order was already cancelled in the database
+			logger.WarnContext(ctx, "Failed to publish order event", "event", rabbitmq.OrderCancelled, "error", err)
 		}
 
+		ordersCounter.Inc(orderLabels{Operation: "cancelled"})
+		logger.InfoContext(ctx, "Order cancelled", "customer_id", order.CustomerID, "reason", order.CancelReason)
+
 		return resp, nil
 	})
 
@@ -278,40 +416,122 @@ func RegisterOrdersRoutes(api huma.API, dbConn *gorm.DB, ch *amqp.Channel) {
 		DefaultStatus: http.StatusNoContent,
 		Path:          "/orders/{id}",
 		Tags:          []string{"orders"},
+		Security:      writeAccess,
 	}, func(ctx context.Context, input *struct {
 		Id uint `path:"id"`
 	}) (*struct{}, error) {
 		resp := &struct{}{}
+		ctx = logging.With(ctx, "order_id", input.Id)
 
-		// First get the order to have the complete data for the event
-		var order models.Order
-		result := dbConn.First(&order, input.Id)
+		order, err := orderRepo.Delete(ctx, input.Id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, problem.New(problem.OrderNotFound, input.Id)
+		}
+		if err != nil {
+			return nil, err
+		}
 
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, huma.NewError(http.StatusNotFound, "Order not found")
+		// Publish order deleted event
+		var simplifiedOrder = rabbitmq.EventOrder{SimplifiedOrder: events.SimplifiedOrder{
+			OrderID:    order.ID,
+			CustomerID: order.CustomerID,
+		}}
+		if err := publisher.PublishOrderEvent(ctx, events.OrderDeleted, simplifiedOrder); err != nil {
+			// Log the error but don't fail the request
+			// The order was already deleted from the database
+			logger.WarnContext(ctx, "Failed to publish order event", "event", events.OrderDeleted, "error", err)
+		}
+
+		ordersCounter.Inc(orderLabels{Operation: "deleted"})
+		logger.InfoContext(ctx, "Order deleted", "customer_id", order.CustomerID)
+
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "restore-order",
+		Summary:     "Restore a deleted order",
+		Description: "Undoes the deletion of an order, which is published again as order.created.",
+		Method:      http.MethodPost,
+		Path:        "/orders/{id}/restore",
+		Tags:        []string{"orders"},
+		Security:    adminAccess,
+	}, func(ctx context.Context, input *struct {
+		Id uint `path:"id"`
+	}) (*dto.OrderOutput, error) {
+		resp := &dto.OrderOutput{}
+		ctx = logging.With(ctx, "order_id", input.Id)
+
+		// Lines never change, read them first so a failure leaves the
+		// order deleted
+		lines, err := orderRepo.Lines(repository.IncludeDeleted(ctx), input.Id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, problem.New(problem.OrderNotFound, input.Id)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		order, err := orderRepo.Restore(ctx, input.Id)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, problem.New(problem.OrderNotFound, input.Id)
+		case errors.Is(err, repository.ErrNotDeleted):
+			return nil, problem.New(problem.OrderNotDeleted, input.Id)
+		case err != nil:
+			return nil, err
 		}
+		resp.Body = order
 
-		if result.Error != nil {
-			return nil, result.Error
+		// The other services forgot the order when it was deleted, publish
+		// it as created again
+		if err := publisher.PublishOrderEvent(ctx, events.OrderCreated, rabbitmq.NewEventOrder(order, lines)); err != nil {
+			// Log the error but don't fail the request
+			// The order was already restored in the database
+			logger.WarnContext(ctx, "Failed to publish order event", "event", events.OrderCreated, "error", err)
 		}
 
-		results := dbConn.Delete(&order)
+		ordersCounter.Inc(orderLabels{Operation: "restored"})
+		logger.InfoContext(ctx, "Order restored", "customer_id", order.CustomerID)
 
-		if results.Error == nil {
-			// Publish order deleted event
-			var simplifiedOrder = events.SimplifiedOrder{
-				OrderID:    order.ID,
-				CustomerID: order.CustomerID,
-			}
-			err := rabbitmq.PublishOrderEvent(ch, events.OrderDeleted, simplifiedOrder)
-			if err != nil {
-				// Log the error but don't fail the request
-				// The order was already deleted from the database
+		return resp, nil
+	})
+
+	huma.Register(api, huma.Operation{
+		OperationID: "purge-orders",
+		Summary:     "Purge deleted orders",
+		Description: "Removes for good the orders deleted for longer than the retention, along with their customer and product lines. " +
+			"Orders that aren't deleted are never purged.",
+		Method:   http.MethodPost,
+		Path:     "/orders/purge",
+		Tags:     []string{"orders"},
+		Security: adminAccess,
+	}, func(ctx context.Context, input *dto.OrdersPurgeInput) (*dto.OrdersPurgeOutput, error) {
+		resp := &dto.OrdersPurgeOutput{}
+
+		retention := cfg.Retention
+		if input.OlderThan != "" {
+			d, err := time.ParseDuration(input.OlderThan)
+			if err != nil || d <= 0 {
+				return nil, huma.Error422UnprocessableEntity("validation failed", &huma.ErrorDetail{
+					Message:  "expected a positive duration, e.g. 720h",
+					Location: "query.olderThan",
+					Value:    input.OlderThan,
+				})
 			}
+			retention = d
+		}
 
-			return resp, nil
+		resp.Body.DeletedBefore = time.Now().UTC().Add(-retention)
+		purged, err := orderRepo.Purge(ctx, resp.Body.DeletedBefore)
+		if err != nil {
+			return nil, err
 		}
+		resp.Body.Purged = purged
+
+		ordersCounter.Add(float64(purged), orderLabels{Operation: "purged"})
+		logger.InfoContext(ctx, "Orders purged", "purged", purged, "deleted_before", resp.Body.DeletedBefore)
 
-		return nil, results.Error
+		return resp, nil
 	})
 }
@@ -1,68 +1,610 @@
-package operation_test
-
-import (
-	"context"
-	"regexp"
-	"testing"
-
-	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/operation"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-)
-
-func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
-	dbMock, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("failed to create sqlmock: %v", err)
-	}
-
-	gormDB, err := gorm.Open(postgres.New(postgres.Config{
-		Conn: dbMock,
-	}), &gorm.Config{})
-	if err != nil {
-		t.Fatalf("failed to open gorm DB: %v", err)
-	}
-
-	return gormDB, mock
-}
-
-func TestGetOrders(t *testing.T) {
-	db, mock := setupMockDB(t)
-
-	rows := sqlmock.NewRows([]string{"id", "customer_id"}).
-		AddRow(1, "1").
-		AddRow(2, "3")
-
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders"`)).WillReturnRows(rows)
-
-	resp, err := operation.GetOrders(context.Background(), db)
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
-	}
-
-	if len(resp.Body.Orders) != 2 {
-		t.Errorf("expected 2 orders, got %d", len(resp.Body.Orders))
-	}
-
-	if resp.Body.Orders[0].ID != 1 {
-		t.Errorf("expected first order '1', got '%d'", resp.Body.Orders[0].ID)
-	}
-
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("unfulfilled sqlmock expectations: %v", err)
-	}
-}
-
-func TestGetOrderNotFound(t *testing.T) {
-	db, mock := setupMockDB(t)
-
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE "orders"."id" = $1`)).
-		WithArgs(1, sqlmock.AnyArg()).
-		WillReturnError(gorm.ErrRecordNotFound)
-
-	_, err := operation.GetOrder(context.Background(), db, 1)
-	if err == nil {
-		t.Fatal("expected error for non-existent order")
-	}
-}
+package operation_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth/authtest"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db/dbtest"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/operation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/problem"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"gorm.io/gorm"
+)
+
+// setupRepos returns repositories backed by a fresh SQLite database, where
+// customers 1 to 5 and products 1 to 9 exist
+func setupRepos(t *testing.T) (repository.Repositories, *gorm.DB) {
+	t.Helper()
+	ctx := context.Background()
+
+	db := dbtest.New(t)
+	repos := repository.NewGorm(db)
+	for id := uint(1); id <= 9; id++ {
+		if id <= 5 {
+			if err := repos.Customers.Create(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := repos.Products.Create(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repos, db
+}
+
+func TestGetOrders(t *testing.T) {
+	repos, _ := setupRepos(t)
+	for _, customerID := range []uint{1, 3} {
+		if err := repos.Orders.Create(context.Background(), &localModels.Order{Order: models.Order{CustomerID: customerID}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := operation.GetOrders(context.Background(), repos.Orders)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(resp.Body.Orders) != 2 {
+		t.Errorf("expected 2 orders, got %d", len(resp.Body.Orders))
+	}
+
+	if resp.Body.Orders[0].ID != 1 {
+		t.Errorf("expected first order '1', got '%d'", resp.Body.Orders[0].ID)
+	}
+}
+
+func TestGetOrderNotFound(t *testing.T) {
+	repos, _ := setupRepos(t)
+
+	_, err := operation.GetOrder(context.Background(), repos.Orders, nil, slog.New(slog.DiscardHandler), 1)
+	var statusErr huma.StatusError
+	if !errors.As(err, &statusErr) || statusErr.GetStatus() != http.StatusNotFound {
+		t.Fatalf("expected a 404 error for non-existent order, got %v", err)
+	}
+}
+
+func TestGetOrderWithProducts(t *testing.T) {
+	products := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/products/1/orders" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"products":[{"ID":7,"name":"Arabica"}]}`))
+	}))
+	defer products.Close()
+
+	repos, _ := setupRepos(t)
+	orders := repos.Orders
+	order := localModels.Order{Order: models.Order{CustomerID: 3}}
+	if err := orders.Create(context.Background(), &order, []repository.OrderLine{{ProductID: 7, Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := operation.NewProductsClient(config.Products{URL: products.URL, Timeout: time.Second})
+	resp, err := operation.GetOrder(context.Background(), orders, client, slog.New(slog.DiscardHandler), order.ID)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if resp.Body.CustomerID != 3 {
+		t.Errorf("expected customer 3, got %d", resp.Body.CustomerID)
+	}
+	if len(resp.Body.Products) != 1 || resp.Body.Products[0].Name != "Arabica" {
+		t.Errorf("expected products from the Products service, got %v", resp.Body.Products)
+	}
+}
+
+func TestOrderValue(t *testing.T) {
+	products := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/products/7":
+			w.Write([]byte(`{"ID":7,"name":"Arabica","details":{"price":12.5}}`))
+		case "/products/8":
+			w.Write([]byte(`{"ID":8,"name":"Robusta","details":{"price":4}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer products.Close()
+
+	client := operation.NewProductsClient(config.Products{URL: products.URL, Timeout: time.Second})
+	value, err := client.OrderValue(context.Background(), []repository.OrderLine{{ProductID: 7, Quantity: 2}, {ProductID: 8, Quantity: 3}})
+	if err != nil || value != 37 {
+		t.Errorf("expected a value of 37, got %v, %v", value, err)
+	}
+
+	if _, err := client.OrderValue(context.Background(), []repository.OrderLine{{ProductID: 9, Quantity: 1}}); err == nil {
+		t.Error("expected an unknown product to fail pricing")
+	}
+}
+
+func TestGetOrdersByIdCustomer(t *testing.T) {
+	// The Products service being down doesn't fail the request
+	products := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer products.Close()
+
+	repos, _ := setupRepos(t)
+	orders := repos.Orders
+	for _, customerID := range []uint{1, 2, 1} {
+		if err := orders.Create(context.Background(), &localModels.Order{Order: models.Order{CustomerID: customerID}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := orders.Delete(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	client := operation.NewProductsClient(config.Products{URL: products.URL, Timeout: time.Second})
+	resp, err := operation.GetOrdersByIdCustomer(context.Background(), orders, client, slog.New(slog.DiscardHandler), 1)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(resp.Body.Orders) != 1 || resp.Body.Orders[0].ID != 1 {
+		t.Errorf("expected only order 1 for customer 1, got %v", resp.Body.Orders)
+	}
+}
+
+// testLimits are the limits of the orders accepted by the test APIs
+var testLimits = config.Orders{MaxLines: 3, MaxQuantity: 10, Retention: time.Hour}
+
+// setupOrdersAPI registers the order routes on a test API backed by the
+// repositories of setupRepos, reporting errors as problems
+func setupOrdersAPI(t *testing.T, publisher rabbitmq.EventPublisher) (humatest.TestAPI, repository.OrderRepository, *gorm.DB) {
+	logger := slog.New(slog.DiscardHandler)
+	cfg := huma.DefaultConfig("Orders", "1.0.0")
+	problem.Install(&cfg, logger)
+	_, api := humatest.New(t, cfg)
+
+	repos, db := setupRepos(t)
+	client := operation.NewProductsClient(config.Products{URL: "http://127.0.0.1:0", Timeout: time.Second})
+	operation.RegisterOrdersRoutes(api, repos.Orders, publisher, client, testLimits, logger)
+	return api, repos.Orders, db
+}
+
+func TestCreateOrder(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, _, db := setupOrdersAPI(t, publisher)
+
+	resp := api.Post("/orders", map[string]any{"customerId": 3, "productIds": []uint{7, 2}})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var lines []uint
+	if err := db.Model(&localModels.OrderProduct{}).Where("order_id = ?", 1).Order("product_id").Pluck("product_id", &lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0] != 2 || lines[1] != 7 {
+		t.Errorf("expected product lines [2 7], got %v", lines)
+	}
+
+	recorded := publisher.Events()
+	if len(recorded) != 1 || recorded[0].Type != events.OrderCreated {
+		t.Fatalf("expected one order.created event, got %v", recorded)
+	}
+	if order := recorded[0].Order; order.OrderID != 1 || order.CustomerID != 3 || len(order.ProductIDs) != 2 {
+		t.Errorf("expected the event to describe order 1, got %+v", order)
+	}
+}
+
+func TestCreateOrderLines(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, _, db := setupOrdersAPI(t, publisher)
+
+	resp := api.Post("/orders", map[string]any{"customerId": 3, "lines": []map[string]any{
+		{"productId": 4, "quantity": 5},
+		{"productId": 2},
+	}})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var lines []localModels.OrderProduct
+	if err := db.Where("order_id = ?", 1).Order("product_id").Find(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0].ProductID != 2 || lines[0].Quantity != 1 || lines[1].ProductID != 4 || lines[1].Quantity != 5 {
+		t.Errorf("expected 1 unit of product 2 and 5 of product 4, got %+v", lines)
+	}
+	if recorded := publisher.Events(); len(recorded) != 1 || len(recorded[0].Order.ProductIDs) != 2 {
+		t.Errorf("expected an order.created event with both products, got %v", recorded)
+	}
+}
+
+func TestCreateOrderPendingStock(t *testing.T) {
+	_, api := humatest.New(t)
+	repos, _ := setupRepos(t)
+	client := operation.NewProductsClient(config.Products{URL: "http://127.0.0.1:0", Timeout: time.Second})
+	cfg := testLimits
+	cfg.Reservation = config.Reservation{Enabled: true, Timeout: time.Minute}
+	operation.RegisterOrdersRoutes(api, repos.Orders, &rabbitmq.RecordingPublisher{}, client, cfg, slog.New(slog.DiscardHandler))
+
+	before := time.Now()
+	resp := api.Post("/orders", map[string]any{"customerId": 3, "productIds": []uint{7}})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	order, err := repos.Orders.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != localModels.OrderPendingStock {
+		t.Errorf("expected the order to wait for its stock, got %s", order.Status)
+	}
+	if expires := order.ReservationExpiresAt; expires == nil || expires.Before(before.Add(time.Minute)) || expires.After(time.Now().Add(time.Minute)) {
+		t.Errorf("expected the reservation to expire in a minute, got %v", expires)
+	}
+}
+
+func TestCreateOrderValidation(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, _, _ := setupOrdersAPI(t, publisher)
+
+	line := func(productID, quantity int) map[string]any {
+		return map[string]any{"productId": productID, "quantity": quantity}
+	}
+	for name, test := range map[string]struct {
+		body     map[string]any
+		location string
+	}{
+		"no customer":        {map[string]any{"customerId": 0, "productIds": []uint{1}}, "body.customerId"},
+		"no lines":           {map[string]any{"customerId": 1}, "body.lines"},
+		"empty lines":        {map[string]any{"customerId": 1, "lines": []any{}}, "body.lines"},
+		"lines and products": {map[string]any{"customerId": 1, "productIds": []uint{1}, "lines": []any{line(2, 1)}}, "body.productIds"},
+		"duplicate products": {map[string]any{"customerId": 1, "productIds": []uint{1, 1}}, "body.productIds"},
+		"duplicate lines":    {map[string]any{"customerId": 1, "lines": []any{line(1, 1), line(1, 2)}}, "body.lines[1].productId"},
+		"zero quantity":      {map[string]any{"customerId": 1, "lines": []any{line(1, 0)}}, "body.lines[0].quantity"},
+		"too many lines":     {map[string]any{"customerId": 1, "productIds": []uint{1, 2, 3, 4}}, "body.productIds"},
+		"too many units":     {map[string]any{"customerId": 1, "lines": []any{line(1, 6), line(2, 5)}}, "body.lines"},
+		"huge lines":         {map[string]any{"customerId": 1, "lines": []any{line(1, 1<<62), line(2, 1<<62)}}, "body.lines[0].quantity"},
+	} {
+		resp := api.Post("/orders", test.body)
+		var p problem.Problem
+		if err := json.Unmarshal(resp.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != http.StatusUnprocessableEntity || p.Code != problem.ValidationFailed ||
+			len(p.Errors) == 0 || p.Errors[0].Location != test.location {
+			t.Errorf("%s: expected a validation error at %s, got %d %s", name, test.location, resp.Code, resp.Body.String())
+		}
+	}
+	if len(publisher.Events()) != 0 {
+		t.Errorf("expected no event for invalid orders, got %v", publisher.Events())
+	}
+
+	// The configured limits are documented
+	schema := api.OpenAPI().Components.Schemas.Map()["OrderCreateInputBody"]
+	if maxItems := schema.Properties["lines"].MaxItems; maxItems == nil || *maxItems != testLimits.MaxLines {
+		t.Errorf("expected lines to have at most %d items in the OpenAPI document, got %v", testLimits.MaxLines, maxItems)
+	}
+	quantity := api.OpenAPI().Components.Schemas.Map()["OrderLineInput"].Properties["quantity"]
+	if maximum := quantity.Maximum; maximum == nil || *maximum != float64(testLimits.MaxQuantity) {
+		t.Errorf("expected quantities of at most %d in the OpenAPI document, got %v", testLimits.MaxQuantity, maximum)
+	}
+}
+
+func TestCreateOrderUnknownReferences(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, _, _ := setupOrdersAPI(t, publisher)
+
+	for code, body := range map[problem.Code]map[string]any{
+		problem.UnknownCustomer: {"customerId": 42, "productIds": []uint{1}},
+		problem.UnknownProduct:  {"customerId": 1, "productIds": []uint{1, 42}},
+	} {
+		resp := api.Post("/orders", body)
+		var p problem.Problem
+		if err := json.Unmarshal(resp.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != http.StatusUnprocessableEntity || p.Code != code {
+			t.Errorf("expected 422 %s, got %d %s", code, resp.Code, resp.Body.String())
+		}
+	}
+	if len(publisher.Events()) != 0 {
+		t.Errorf("expected no event for rejected orders, got %v", publisher.Events())
+	}
+}
+
+func TestCreateOrderPublishFailure(t *testing.T) {
+	// The order is stored, so a broker failure doesn't fail the request
+	publisher := &rabbitmq.RecordingPublisher{Err: errors.New("broker unavailable")}
+	api, orders, _ := setupOrdersAPI(t, publisher)
+
+	resp := api.Post("/orders", map[string]any{"customerId": 3, "productIds": []uint{7}})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if _, err := orders.Get(context.Background(), 1); err != nil {
+		t.Errorf("expected the order to be stored, got %v", err)
+	}
+}
+
+func TestPutOrder(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, orders, _ := setupOrdersAPI(t, publisher)
+	if err := orders.Create(context.Background(), &localModels.Order{Order: models.Order{CustomerID: 3}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := api.Put("/orders/1", map[string]any{"customerId": 5, "productIds": []uint{}})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	order, _ := orders.Get(context.Background(), 1)
+	if order.CustomerID != 5 {
+		t.Errorf("expected customer 5, got %d", order.CustomerID)
+	}
+
+	recorded := publisher.Events()
+	if len(recorded) != 1 || recorded[0].Type != events.OrderUpdated || recorded[0].Order.CustomerID != 5 {
+		t.Errorf("expected one order.updated event for customer 5, got %v", recorded)
+	}
+
+	resp = api.Put("/orders/42", map[string]any{"customerId": 5, "productIds": []uint{}})
+	if resp.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", resp.Code)
+	}
+	if len(publisher.Events()) != 1 {
+		t.Errorf("expected no event for a missing order, got %v", publisher.Events())
+	}
+
+	// Cancelled orders can't be changed
+	if resp := api.Post("/orders/1/cancel", map[string]any{"reason": "duplicate"}); resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	resp = api.Put("/orders/1", map[string]any{"customerId": 6})
+	var p problem.Problem
+	if err := json.Unmarshal(resp.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusConflict || p.Code != problem.InvalidTransition {
+		t.Errorf("expected 409 %s updating a cancelled order, got %d %s", problem.InvalidTransition, resp.Code, resp.Body.String())
+	}
+	if order, _ := orders.Get(context.Background(), 1); order.CustomerID != 5 {
+		t.Errorf("expected the customer to be left unchanged, got %d", order.CustomerID)
+	}
+	if recorded := publisher.Events(); len(recorded) != 2 || recorded[1].Type != rabbitmq.OrderCancelled {
+		t.Errorf("expected no order.updated event for a cancelled order, got %v", recorded)
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, orders, _ := setupOrdersAPI(t, publisher)
+
+	resp := api.Post("/orders", map[string]any{"customerId": 3, "lines": []map[string]any{
+		{"productId": 4, "quantity": 5},
+		{"productId": 2},
+	}})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	resp = api.Post("/orders/1/cancel", map[string]any{"reason": "customer_request", "note": "Ordered twice"})
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	// The order stays visible with its cancellation
+	order, err := orders.Get(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != localModels.OrderCancelled || order.CancelReason != localModels.CancelCustomerRequest || order.CancelNote != "Ordered twice" {
+		t.Errorf("expected the order to be cancelled, got %+v", order)
+	}
+
+	recorded := publisher.Events()
+	if len(recorded) != 2 || recorded[1].Type != rabbitmq.OrderCancelled {
+		t.Fatalf("expected an order.cancelled event, got %v", recorded)
+	}
+	cancelled := recorded[1].Order
+	if len(cancelled.Lines) != 2 || cancelled.Lines[1] != (rabbitmq.EventOrderLine{ProductID: 4, Quantity: 5}) {
+		t.Errorf("expected the event to carry the lines, got %+v", cancelled.Lines)
+	}
+	if cancelled.Cancellation == nil || cancelled.Cancellation.Reason != "customer_request" {
+		t.Errorf("expected the event to carry the cancellation, got %+v", cancelled.Cancellation)
+	}
+
+	resp = api.Post("/orders/1/cancel", map[string]any{"reason": "other"})
+	if resp.Code != http.StatusConflict || !strings.Contains(resp.Body.String(), `"code":"INVALID_TRANSITION"`) {
+		t.Errorf("expected INVALID_TRANSITION cancelling twice, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := api.Post("/orders/1/cancel", map[string]any{"reason": "bored"}); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for an unknown reason, got %d", resp.Code)
+	}
+	if resp := api.Post("/orders/9/cancel", map[string]any{"reason": "other"}); resp.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing order, got %d", resp.Code)
+	}
+	if len(publisher.Events()) != 2 {
+		t.Errorf("expected a single order.cancelled event, got %v", publisher.Events())
+	}
+}
+
+func TestDeleteOrder(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, orders, _ := setupOrdersAPI(t, publisher)
+	if err := orders.Create(context.Background(), &localModels.Order{Order: models.Order{CustomerID: 3}}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := api.Delete("/orders/1")
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if _, err := orders.Get(context.Background(), 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected the order to be deleted, got %v", err)
+	}
+
+	recorded := publisher.Events()
+	if len(recorded) != 1 || recorded[0].Type != events.OrderDeleted || recorded[0].Order.OrderID != 1 {
+		t.Errorf("expected one order.deleted event for order 1, got %v", recorded)
+	}
+
+	resp = api.Delete("/orders/1")
+	if resp.Code != http.StatusNotFound || !strings.Contains(resp.Body.String(), `"code":"ORDER_NOT_FOUND"`) {
+		t.Errorf("expected ORDER_NOT_FOUND for a deleted order, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestRestoreAndPurgeOrders(t *testing.T) {
+	publisher := &rabbitmq.RecordingPublisher{}
+	api, _, db := setupOrdersAPI(t, publisher)
+
+	if resp := api.Post("/orders", map[string]any{"customerId": 3, "productIds": []uint{1, 2}}); resp.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", resp.Code, resp.Body.String())
+	}
+	if resp := api.Delete("/orders/1"); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var list struct{ Orders []localModels.Order }
+	resp := api.Get("/orders?includeDeleted=true")
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Orders) != 1 || !list.Orders[0].DeletedAt.Valid {
+		t.Errorf("expected the deleted order to be listed, got %d %v", resp.Code, list.Orders)
+	}
+	if resp := api.Get("/orders/1?includeDeleted=true"); resp.Code != http.StatusOK {
+		t.Errorf("expected the deleted order to be found, got %d", resp.Code)
+	}
+
+	resp = api.Post("/orders/1/restore")
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+	recorded := publisher.Events()
+	if len(recorded) != 3 || recorded[2].Type != events.OrderCreated || len(recorded[2].Order.Lines) != 2 {
+		t.Errorf("expected order.created to be published again with the lines, got %+v", recorded)
+	}
+	resp = api.Post("/orders/1/restore")
+	if resp.Code != http.StatusConflict || !strings.Contains(resp.Body.String(), `"code":"ORDER_NOT_DELETED"`) {
+		t.Errorf("expected ORDER_NOT_DELETED restoring twice, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := api.Post("/orders/9/restore"); resp.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a missing order, got %d", resp.Code)
+	}
+
+	// Only orders deleted for longer than the retention are purged
+	if resp := api.Delete("/orders/1"); resp.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", resp.Code)
+	}
+	var purge struct{ Purged int64 }
+	resp = api.Post("/orders/purge")
+	if err := json.Unmarshal(resp.Body.Bytes(), &purge); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusOK || purge.Purged != 0 {
+		t.Errorf("expected nothing to be purged within the retention, got %d %s", resp.Code, resp.Body.String())
+	}
+	resp = api.Post("/orders/purge?olderThan=1ns")
+	if err := json.Unmarshal(resp.Body.Bytes(), &purge); err != nil {
+		t.Fatal(err)
+	}
+	if purge.Purged != 1 {
+		t.Errorf("expected the order to be purged, got %s", resp.Body.String())
+	}
+	var lines int64
+	if err := db.Model(&localModels.OrderProduct{}).Where("order_id = ?", 1).Count(&lines).Error; err != nil || lines != 0 {
+		t.Errorf("expected the lines to be purged, got %d %v", lines, err)
+	}
+	if resp := api.Post("/orders/purge?olderThan=soon"); resp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status 422 for an invalid duration, got %d", resp.Code)
+	}
+}
+
+func TestOrdersAuthorization(t *testing.T) {
+	issuer := authtest.NewIssuer(t)
+	cfg := huma.DefaultConfig("Orders", "1.0.0")
+	problem.Install(&cfg, slog.New(slog.DiscardHandler))
+	_, api := humatest.New(t, cfg)
+	api.UseMiddleware(auth.Middleware(api, issuer.Verifier(), nil, slog.New(slog.DiscardHandler)))
+
+	repos, _ := setupRepos(t)
+	for _, customerID := range []uint{1, 2} {
+		if err := repos.Orders.Create(context.Background(), &localModels.Order{Order: models.Order{CustomerID: customerID}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := operation.NewProductsClient(config.Products{URL: "http://127.0.0.1:0", Timeout: time.Second})
+	operation.RegisterOrdersRoutes(api, repos.Orders, &rabbitmq.RecordingPublisher{}, client, testLimits, slog.New(slog.DiscardHandler))
+
+	customer := issuer.Authorization(auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleCustomer}, CustomerID: 1})
+	support := issuer.Authorization(auth.Principal{Subject: "bob", Roles: []auth.Role{auth.RoleSupport}})
+
+	if resp := api.Get("/orders"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", resp.Code)
+	}
+
+	// Customers only see their own orders
+	resp := api.Get("/orders", customer)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+	var list struct{ Orders []localModels.Order }
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Orders) != 1 || list.Orders[0].CustomerID != 1 {
+		t.Errorf("expected only the orders of customer 1, got %v", list.Orders)
+	}
+	if resp := api.Get("/orders/2", customer); resp.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for the order of another customer, got %d", resp.Code)
+	}
+	resp = api.Get("/orders/2/customers", customer)
+	if err := json.Unmarshal(resp.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusOK || len(list.Orders) != 0 {
+		t.Errorf("expected no order for another customer, got %d %v", resp.Code, list.Orders)
+	}
+
+	// Customers without a customer ID are refused rather than shown nothing
+	unknown := issuer.Authorization(auth.Principal{Subject: "carol", Roles: []auth.Role{auth.RoleCustomer}})
+	for _, path := range []string{"/orders", "/orders/1", "/orders/1/customers"} {
+		if resp := api.Get(path, unknown); resp.Code != http.StatusForbidden {
+			t.Errorf("expected 403 for %s without a customer ID, got %d", path, resp.Code)
+		}
+	}
+
+	// Writes require support or admin
+	if resp := api.Delete("/orders/1", customer); resp.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a customer deleting an order, got %d", resp.Code)
+	}
+	if resp := api.Delete("/orders/2", support); resp.Code != http.StatusNoContent {
+		t.Errorf("expected support to delete an order, got %d", resp.Code)
+	}
+
+	// Deleted orders are for admins only
+	if resp := api.Get("/orders?includeDeleted=true", support); resp.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for support including deleted orders, got %d", resp.Code)
+	}
+	if resp := api.Post("/orders/2/restore", support); resp.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for support restoring an order, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/dto"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// ProductsClient calls the Products service, propagating the trace context
+type ProductsClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewProductsClient creates a client for the Products service at cfg.URL
+func NewProductsClient(cfg config.Products) *ProductsClient {
+	return &ProductsClient{
+		baseURL: strings.TrimSuffix(cfg.URL, "/"),
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+// OrderProducts fetches the products of an order
+func (c *ProductsClient) OrderProducts(ctx context.Context, orderID uint) ([]models.Product, error) {
+	var productsResp dto.ProductsOutputBody
+	if err := c.get(ctx, fmt.Sprintf("/products/%d/orders", orderID), &productsResp); err != nil {
+		return nil, err
+	}
+	return productsResp.Products, nil
+}
+
+// Product fetches a product by ID
+func (c *ProductsClient) Product(ctx context.Context, id uint) (models.Product, error) {
+	var product models.Product
+	err := c.get(ctx, fmt.Sprintf("/products/%d", id), &product)
+	return product, err
+}
+
+// OrderValue returns the value of the lines of an order at the current
+// prices of their products
+func (c *ProductsClient) OrderValue(ctx context.Context, lines []repository.OrderLine) (float64, error) {
+	value := 0.0
+	for _, line := range lines {
+		product, err := c.Product(ctx, line.ProductID)
+		if err != nil {
+			return 0, err
+		}
+		value += float64(product.Details.Price) * float64(line.Quantity)
+	}
+	return value, nil
+}
+
+// get decodes the JSON response to a GET of path into v, recording the
+// latency and failures of the request
+func (c *ProductsClient) get(ctx context.Context, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	r, err := c.client.Do(req)
+	if err != nil {
+		productsAPIHistogram.Observe(time.Since(start).Seconds(), productsAPILabels{Status: "error"})
+		productsAPIErrorsCounter.Inc(productsAPIErrorLabels{Reason: "transport"})
+		return err
+	}
+	defer r.Body.Close()
+
+	productsAPIHistogram.Observe(time.Since(start).Seconds(), productsAPILabels{Status: strconv.Itoa(r.StatusCode)})
+	if r.StatusCode != http.StatusOK {
+		productsAPIErrorsCounter.Inc(productsAPIErrorLabels{Reason: "status"})
+		return fmt.Errorf("products API returned status %d", r.StatusCode)
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		productsAPIErrorsCounter.Inc(productsAPIErrorLabels{Reason: "decode"})
+		return fmt.Errorf("failed to decode products response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package policy enforces row-level access rules on top of the repositories
+package policy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+)
+
+// staffRoles see every order
+var staffRoles = []auth.Role{auth.RoleSupport, auth.RoleAdmin}
+
+// IsAdmin tells whether the caller of ctx is an admin: a user with the admin
+// role, a service with the admin scope or an internal call
+func IsAdmin(ctx context.Context) bool {
+	p, ok := auth.FromContext(ctx)
+	return !ok || p.HasRole(auth.RoleAdmin) || p.HasScope(auth.ScopeAdmin)
+}
+
+// ErrNoCustomer is returned to customers whose token tells no customer ID,
+// rather than scoping them to no order
+var ErrNoCustomer = errors.New("customer without a customer ID")
+
+// CustomerScope returns the customer the caller of ctx is restricted to.
+// Staff, services calling with an API key and internal calls, which carry
+// no principal, aren't restricted. It returns ErrNoCustomer for customers
+// without a customer ID.
+func CustomerScope(ctx context.Context) (uint, bool, error) {
+	p, ok := auth.FromContext(ctx)
+	if !ok || !p.HasRole(auth.RoleCustomer) || p.HasRole(staffRoles...) {
+		return 0, false, nil
+	}
+	if p.CustomerID == 0 {
+		return 0, true, ErrNoCustomer
+	}
+	return p.CustomerID, true, nil
+}
+
+// Orders is an OrderRepository only showing customers their own orders.
+// The orders of other customers are reported as repository.ErrNotFound, so
+// their IDs can't be told apart from missing ones.
+type Orders struct {
+	next repository.OrderRepository
+}
+
+// NewOrders scopes next to the caller of each request
+func NewOrders(next repository.OrderRepository) *Orders {
+	return &Orders{next: next}
+}
+
+func (o *Orders) List(ctx context.Context) ([]models.Order, error) {
+	customerID, scoped, err := CustomerScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if scoped {
+		return o.next.ListByCustomer(ctx, customerID)
+	}
+	return o.next.List(ctx)
+}
+
+func (o *Orders) Get(ctx context.Context, id uint) (models.Order, error) {
+	customerID, scoped, err := CustomerScope(ctx)
+	if err != nil {
+		return models.Order{}, err
+	}
+	order, err := o.next.Get(ctx, id)
+	if err != nil {
+		return order, err
+	}
+	if scoped && order.CustomerID != customerID {
+		return models.Order{}, repository.ErrNotFound
+	}
+	return order, nil
+}
+
+func (o *Orders) ListByCustomer(ctx context.Context, customerID uint) ([]models.Order, error) {
+	own, scoped, err := CustomerScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if scoped && customerID != own {
+		return []models.Order{}, nil
+	}
+	return o.next.ListByCustomer(ctx, customerID)
+}
+
+// Create isn't scoped, the roles allowed to write orders are checked by the
+// API
+func (o *Orders) Create(ctx context.Context, order *models.Order, lines []repository.OrderLine) error {
+	return o.next.Create(ctx, order, lines)
+}
+
+func (o *Orders) UpdateCustomer(ctx context.Context, id uint, customerID uint) (models.Order, error) {
+	if _, err := o.Get(ctx, id); err != nil {
+		return models.Order{}, err
+	}
+	return o.next.UpdateCustomer(ctx, id, customerID)
+}
+
+func (o *Orders) ChangeStatus(ctx context.Context, id uint, change repository.StatusChange) (models.Order, error) {
+	if _, err := o.Get(ctx, id); err != nil {
+		return models.Order{}, err
+	}
+	return o.next.ChangeStatus(ctx, id, change)
+}
+
+func (o *Orders) Lines(ctx context.Context, id uint) ([]repository.OrderLine, error) {
+	if _, err := o.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return o.next.Lines(ctx, id)
+}
+
+func (o *Orders) Delete(ctx context.Context, id uint) (models.Order, error) {
+	if _, err := o.Get(ctx, id); err != nil {
+		return models.Order{}, err
+	}
+	return o.next.Delete(ctx, id)
+}
+
+// ListExpiredReservations isn't scoped, only the reservation saga calls it
+func (o *Orders) ListExpiredReservations(ctx context.Context, before time.Time) ([]models.Order, error) {
+	return o.next.ListExpiredReservations(ctx, before)
+}
+
+// Restore isn't scoped, only admins restore orders
+func (o *Orders) Restore(ctx context.Context, id uint) (models.Order, error) {
+	return o.next.Restore(ctx, id)
+}
+
+// Purge isn't scoped, only admins purge orders
+func (o *Orders) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return o.next.Purge(ctx, deletedBefore)
+}
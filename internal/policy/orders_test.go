@@ -0,0 +1,69 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/policy"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+)
+
+func TestOrdersPolicy(t *testing.T) {
+	repo := repository.NewMemoryOrderRepository()
+	for _, customerID := range []uint{1, 2, 1} {
+		if err := repo.Create(context.Background(), &localModels.Order{Order: models.Order{CustomerID: customerID}}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orders := policy.NewOrders(repo)
+
+	customer := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "alice", Roles: []auth.Role{auth.RoleCustomer}, CustomerID: 1})
+	support := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "bob", Roles: []auth.Role{auth.RoleCustomer, auth.RoleSupport}})
+
+	list, err := orders.List(customer)
+	if err != nil || len(list) != 2 {
+		t.Errorf("expected the 2 orders of customer 1, got %v, %v", list, err)
+	}
+	if list, _ := orders.List(support); len(list) != 3 {
+		t.Errorf("expected staff to see every order, got %v", list)
+	}
+	if list, _ := orders.ListByCustomer(customer, 2); len(list) != 0 {
+		t.Errorf("expected no order of customer 2, got %v", list)
+	}
+
+	// Other customers' orders look missing
+	if _, err := orders.Get(customer, 2); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the order of another customer, got %v", err)
+	}
+	if _, err := orders.Delete(customer, 2); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting the order of another customer, got %v", err)
+	}
+	if _, err := orders.Get(support, 2); err != nil {
+		t.Errorf("expected staff to read any order, got %v", err)
+	}
+
+	// Neither are services calling with an API key, nor internal calls
+	service := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "key:abc", Scopes: []auth.Scope{auth.ScopeOrdersRead}})
+	if list, _ := orders.List(service); len(list) != 3 {
+		t.Errorf("expected an API key to see every order, got %v", list)
+	}
+	if _, err := orders.Get(context.Background(), 2); err != nil {
+		t.Errorf("expected an unscoped read without principal, got %v", err)
+	}
+
+	// Customers without a customer ID are refused
+	unknown := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "carol", Roles: []auth.Role{auth.RoleCustomer}})
+	if _, err := orders.List(unknown); !errors.Is(err, policy.ErrNoCustomer) {
+		t.Errorf("expected ErrNoCustomer listing orders, got %v", err)
+	}
+	if _, err := orders.Get(unknown, 1); !errors.Is(err, policy.ErrNoCustomer) {
+		t.Errorf("expected ErrNoCustomer reading an order, got %v", err)
+	}
+	if _, err := orders.ListByCustomer(unknown, 0); !errors.Is(err, policy.ErrNoCustomer) {
+		t.Errorf("expected ErrNoCustomer listing the orders of a customer, got %v", err)
+	}
+}
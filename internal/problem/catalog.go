@@ -0,0 +1,140 @@
+package problem
+
+import "net/http"
+
+// Code identifies a kind of problem. Codes are part of the API, clients
+// may rely on them, so they are never renamed nor reused.
+type Code string
+
+// Generic codes, for the errors of a given HTTP status
+const (
+	BadRequest           Code = "BAD_REQUEST"
+	Unauthenticated      Code = "UNAUTHENTICATED"
+	Forbidden            Code = "FORBIDDEN"
+	NotFound             Code = "NOT_FOUND"
+	NotAcceptable        Code = "NOT_ACCEPTABLE"
+	Conflict             Code = "CONFLICT"
+	UnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	ValidationFailed     Code = "VALIDATION_FAILED"
+	RateLimited          Code = "RATE_LIMITED"
+	Internal             Code = "INTERNAL_ERROR"
+	Unavailable          Code = "UNAVAILABLE"
+)
+
+// Domain codes
+const (
+	OrderNotFound      Code = "ORDER_NOT_FOUND"
+	OrderNotDeleted    Code = "ORDER_NOT_DELETED"
+	UnknownCustomer    Code = "UNKNOWN_CUSTOMER"
+	UnknownProduct     Code = "UNKNOWN_PRODUCT"
+	InvalidReference   Code = "INVALID_REFERENCE"
+	InvalidTransition  Code = "INVALID_TRANSITION"
+	EventNotInjectable Code = "EVENT_NOT_INJECTABLE"
+)
+
+// Language of the messages
+type Language string
+
+const (
+	English Language = "en"
+	French  Language = "fr"
+)
+
+// languages are the supported languages, the first is the default
+var languages = []Language{English, French}
+
+// text is a message in every supported language
+type text map[Language]string
+
+// entry describes a kind of problem. The detail is a fmt format receiving
+// the arguments of the problem.
+type entry struct {
+	status int
+	title  text
+	detail text
+}
+
+// catalog lists every kind of problem
+var catalog = map[Code]entry{
+	BadRequest: {http.StatusBadRequest,
+		text{English: "Bad request", French: "Requête incorrecte"},
+		text{English: "The request is malformed", French: "La requête est mal formée"}},
+	Unauthenticated: {http.StatusUnauthorized,
+		text{English: "Authentication required", French: "Authentification requise"},
+		text{English: "Valid credentials are required", French: "Des identifiants valides sont requis"}},
+	Forbidden: {http.StatusForbidden,
+		text{English: "Access denied", French: "Accès refusé"},
+		text{English: "You aren't allowed to do this", French: "Vous n'êtes pas autorisé à faire cela"}},
+	NotFound: {http.StatusNotFound,
+		text{English: "Not found", French: "Introuvable"},
+		text{English: "The resource doesn't exist", French: "La ressource n'existe pas"}},
+	NotAcceptable: {http.StatusNotAcceptable,
+		text{English: "Not acceptable", French: "Non acceptable"},
+		text{English: "None of the accepted content types can be produced", French: "Aucun des types de contenu acceptés ne peut être produit"}},
+	Conflict: {http.StatusConflict,
+		text{English: "Conflict", French: "Conflit"},
+		text{English: "The resource already exists", French: "La ressource existe déjà"}},
+	UnsupportedMediaType: {http.StatusUnsupportedMediaType,
+		text{English: "Unsupported media type", French: "Type de contenu non pris en charge"},
+		text{English: "The body must be JSON", French: "Le corps doit être en JSON"}},
+	ValidationFailed: {http.StatusUnprocessableEntity,
+		text{English: "Invalid input", French: "Données invalides"},
+		text{English: "Some values of the request are invalid", French: "Certaines valeurs de la requête sont invalides"}},
+	RateLimited: {http.StatusTooManyRequests,
+		text{English: "Too many requests", French: "Trop de requêtes"},
+		text{English: "Rate limit exceeded, retry later", French: "Limite de requêtes dépassée, réessayez plus tard"}},
+	Internal: {http.StatusInternalServerError,
+		text{English: "Internal error", French: "Erreur interne"},
+		text{English: "An unexpected error occurred", French: "Une erreur inattendue s'est produite"}},
+	Unavailable: {http.StatusServiceUnavailable,
+		text{English: "Service unavailable", French: "Service indisponible"},
+		text{English: "The service is unavailable, retry later", French: "Le service est indisponible, réessayez plus tard"}},
+
+	OrderNotFound: {http.StatusNotFound,
+		text{English: "Order not found", French: "Commande introuvable"},
+		text{English: "Order %d doesn't exist", French: "La commande %d n'existe pas"}},
+	OrderNotDeleted: {http.StatusConflict,
+		text{English: "Order not deleted", French: "Commande non supprimée"},
+		text{English: "Order %d isn't deleted", French: "La commande %d n'est pas supprimée"}},
+	UnknownCustomer: {http.StatusUnprocessableEntity,
+		text{English: "Unknown customer", French: "Client inconnu"},
+		text{English: "The customer of the order doesn't exist", French: "Le client de la commande n'existe pas"}},
+	UnknownProduct: {http.StatusUnprocessableEntity,
+		text{English: "Unknown product", French: "Produit inconnu"},
+		text{English: "A product of the order doesn't exist", French: "Un produit de la commande n'existe pas"}},
+	InvalidReference: {http.StatusUnprocessableEntity,
+		text{English: "Invalid reference", French: "Référence invalide"},
+		text{English: "A referenced resource doesn't exist", French: "Une ressource référencée n'existe pas"}},
+	InvalidTransition: {http.StatusConflict,
+		text{English: "Invalid transition", French: "Transition invalide"},
+		text{English: "Order %d can't go from %s to %s", French: "La commande %d ne peut pas passer de %s à %s"}},
+	EventNotInjectable: {http.StatusBadRequest,
+		text{English: "Event not injectable", French: "Événement non injectable"},
+		text{English: "Only customer.* and product.* events can be injected, got %s", French: "Seuls les événements customer.* et product.* peuvent être injectés, reçu %s"}},
+}
+
+// byStatus are the codes of the errors only known by their status
+var byStatus = map[int]Code{
+	http.StatusBadRequest:           BadRequest,
+	http.StatusUnauthorized:         Unauthenticated,
+	http.StatusForbidden:            Forbidden,
+	http.StatusNotFound:             NotFound,
+	http.StatusNotAcceptable:        NotAcceptable,
+	http.StatusConflict:             Conflict,
+	http.StatusUnsupportedMediaType: UnsupportedMediaType,
+	http.StatusUnprocessableEntity:  ValidationFailed,
+	http.StatusTooManyRequests:      RateLimited,
+	http.StatusInternalServerError:  Internal,
+	http.StatusServiceUnavailable:   Unavailable,
+}
+
+// codeFor returns the code of an error of the given status
+func codeFor(status int) Code {
+	if code, ok := byStatus[status]; ok {
+		return code
+	}
+	if status >= 500 {
+		return Internal
+	}
+	return BadRequest
+}
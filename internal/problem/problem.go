@@ -0,0 +1,203 @@
+// Package problem reports the errors of the API as RFC 9457 problem details,
+// each with a stable code from the catalog and messages in English or
+// French depending on the Accept-Language of the request.
+package problem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/policy"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/danielgtaylor/huma/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// typePrefix starts the type URI of every problem, followed by its code
+const typePrefix = "urn:paye-ton-kawa:problem:"
+
+// Problem is an error of the API. Its title and detail are translated when
+// the response is written.
+type Problem struct {
+	Type     string              `json:"type" format:"uri" doc:"URI identifying the kind of problem" example:"urn:paye-ton-kawa:problem:ORDER_NOT_FOUND"`
+	Title    string              `json:"title" doc:"Summary of the kind of problem, in the language of the request" example:"Order not found"`
+	Status   int                 `json:"status" doc:"HTTP status code" example:"404"`
+	Detail   string              `json:"detail,omitempty" doc:"Explanation of this occurrence of the problem" example:"Order 12 doesn't exist"`
+	Instance string              `json:"instance,omitempty" format:"uri-reference" doc:"Path of the request that failed" example:"/orders/12"`
+	Code     Code                `json:"code" doc:"Stable machine-readable code of the kind of problem" example:"ORDER_NOT_FOUND"`
+	TraceID  string              `json:"traceId,omitempty" doc:"Trace ID of the request, to quote when reporting the problem"`
+	Errors   []*huma.ErrorDetail `json:"errors,omitempty" doc:"Invalid values of the request"`
+
+	args []any
+	// message replaces the detail of the catalog in English
+	message string
+	cause   error
+}
+
+// New returns the problem of code, args being those of its detail
+func New(code Code, args ...any) *Problem {
+	e, ok := catalog[code]
+	if !ok {
+		panic("problem: unknown code " + string(code))
+	}
+	return &Problem{
+		Type:   typePrefix + string(code),
+		Title:  e.title[English],
+		Status: e.status,
+		Detail: fmt.Sprintf(e.detail[English], args...),
+		Code:   code,
+		args:   args,
+	}
+}
+
+// Error returns the detail in English
+func (p *Problem) Error() string {
+	return p.Detail
+}
+
+// Unwrap returns the error that caused the problem, if any
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// GetStatus returns the HTTP status of the problem
+func (p *Problem) GetStatus() int {
+	return p.Status
+}
+
+// ContentType returns the problem variant of the negotiated content type
+func (p *Problem) ContentType(ct string) string {
+	switch ct {
+	case "application/json":
+		return "application/problem+json"
+	case "application/cbor":
+		return "application/problem+cbor"
+	}
+	return ct
+}
+
+// From returns the problem describing err. Repository errors are mapped to
+// their problem, others are internal errors whose cause isn't disclosed.
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	code := Internal
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		code = NotFound
+	case errors.Is(err, repository.ErrDuplicate):
+		code = Conflict
+	case errors.Is(err, repository.ErrUnknownCustomer):
+		code = UnknownCustomer
+	case errors.Is(err, repository.ErrUnknownProduct):
+		code = UnknownProduct
+	case errors.Is(err, repository.ErrInvalidReference):
+		code = InvalidReference
+	case errors.Is(err, policy.ErrNoCustomer):
+		code = Forbidden
+	}
+	p = New(code)
+	p.cause = err
+	return p
+}
+
+// newError replaces huma.NewError, so huma's own errors are problems as
+// well. Handlers returning a plain error end up here as a 500.
+func newError(status int, msg string, errs ...error) huma.StatusError {
+	if status == http.StatusInternalServerError && len(errs) == 1 {
+		return From(errs[0])
+	}
+
+	p := New(codeFor(status))
+	p.Status = status
+	for _, err := range errs {
+		if detailer, ok := err.(huma.ErrorDetailer); ok {
+			p.Errors = append(p.Errors, detailer.ErrorDetail())
+		} else if status >= http.StatusInternalServerError {
+			p.cause = errors.Join(p.cause, err)
+		} else {
+			p.Errors = append(p.Errors, &huma.ErrorDetail{Message: err.Error()})
+		}
+	}
+	// Only client errors explain themselves
+	if msg != "" && status < http.StatusInternalServerError {
+		p.Detail, p.message = msg, msg
+	}
+	return p
+}
+
+// Install makes huma report every error as a problem, and adds to config
+// the transformer translating problems and logging the cause of server
+// errors. It must be called before registering operations, so the OpenAPI
+// document describes problems.
+func Install(config *huma.Config, logger *slog.Logger) {
+	huma.NewError = newError
+	config.Transformers = append(config.Transformers, func(ctx huma.Context, status string, v any) (any, error) {
+		p, ok := v.(*Problem)
+		if !ok {
+			return v, nil
+		}
+		if p.Status >= http.StatusInternalServerError && p.cause != nil {
+			logger.ErrorContext(ctx.Context(), "Request failed", "code", p.Code, "error", p.cause)
+		}
+		return p.render(ctx), nil
+	})
+}
+
+// render returns the problem translated in the language of the request and
+// identifying it
+func (p *Problem) render(ctx huma.Context) *Problem {
+	lang := negotiate(ctx.Header("Accept-Language"))
+	ctx.SetHeader("Content-Language", string(lang))
+
+	e := catalog[p.Code]
+	out := *p
+	out.Title = e.title[lang]
+	if p.message == "" || lang != English {
+		out.Detail = fmt.Sprintf(e.detail[lang], p.args...)
+	}
+	u := ctx.URL()
+	out.Instance = u.Path
+	out.TraceID = traceID(ctx.Context())
+	return &out
+}
+
+// traceID returns the ID of the trace of ctx, or its correlation ID when it
+// isn't traced
+func traceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return correlation.ID(ctx)
+}
+
+// negotiate picks the supported language preferred by an Accept-Language
+// header, e.g. "fr-CH, fr;q=0.9, en;q=0.8", or the default one
+func negotiate(header string) Language {
+	best, bestQ := languages[0], 0.0
+	for part := range strings.SplitSeq(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		for _, lang := range languages {
+			if Language(primary) == lang && q > bestQ {
+				best, bestQ = lang, q
+			}
+		}
+	}
+	return best
+}
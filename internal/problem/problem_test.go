@@ -0,0 +1,115 @@
+package problem_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/problem"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+func setupAPI(t *testing.T, logs *bytes.Buffer) humatest.TestAPI {
+	cfg := huma.DefaultConfig("Test", "1.0.0")
+	problem.Install(&cfg, slog.New(slog.NewTextHandler(logs, nil)))
+	_, api := humatest.New(t, cfg)
+	api.UseMiddleware(func(ctx huma.Context, next func(huma.Context)) {
+		next(huma.WithContext(ctx, correlation.WithID(ctx.Context(), "corr-1")))
+	})
+
+	huma.Register(api, huma.Operation{Method: http.MethodGet, Path: "/orders/{id}"},
+		func(ctx context.Context, input *struct {
+			ID uint `path:"id" minimum:"1"`
+		}) (*struct{}, error) {
+			switch input.ID {
+			case 1:
+				return nil, repository.ErrDuplicate
+			case 2:
+				return nil, errors.New("pq: connection refused to db:5432")
+			}
+			return nil, problem.New(problem.OrderNotFound, input.ID)
+		})
+	return api
+}
+
+func decode(t *testing.T, body []byte) problem.Problem {
+	t.Helper()
+	var p problem.Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("expected a problem, got %s", body)
+	}
+	return p
+}
+
+func TestProblems(t *testing.T) {
+	var logs bytes.Buffer
+	api := setupAPI(t, &logs)
+
+	resp := api.Get("/orders/12")
+	p := decode(t, resp.Body.Bytes())
+	if resp.Code != http.StatusNotFound || resp.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a 404 problem, got %d %s", resp.Code, resp.Header().Get("Content-Type"))
+	}
+	expected := problem.Problem{
+		Type:     "urn:paye-ton-kawa:problem:ORDER_NOT_FOUND",
+		Title:    "Order not found",
+		Status:   http.StatusNotFound,
+		Detail:   "Order 12 doesn't exist",
+		Instance: "/orders/12",
+		Code:     problem.OrderNotFound,
+		TraceID:  "corr-1",
+	}
+	if p.Type != expected.Type || p.Title != expected.Title || p.Status != expected.Status || p.Detail != expected.Detail ||
+		p.Instance != expected.Instance || p.Code != expected.Code || p.TraceID != expected.TraceID {
+		t.Errorf("expected %+v, got %+v", expected, p)
+	}
+
+	// Repository errors are mapped
+	if resp := api.Get("/orders/1"); resp.Code != http.StatusConflict || decode(t, resp.Body.Bytes()).Code != problem.Conflict {
+		t.Errorf("expected a 409 CONFLICT, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	// Other errors are logged, not disclosed
+	resp = api.Get("/orders/2")
+	if resp.Code != http.StatusInternalServerError || decode(t, resp.Body.Bytes()).Code != problem.Internal {
+		t.Errorf("expected a 500 INTERNAL_ERROR, got %d", resp.Code)
+	}
+	if strings.Contains(resp.Body.String(), "db:5432") || !strings.Contains(logs.String(), "db:5432") {
+		t.Errorf("expected the cause to be logged only, got %s", resp.Body.String())
+	}
+
+	// Huma's errors are problems as well
+	resp = api.Get("/orders/0")
+	if p := decode(t, resp.Body.Bytes()); resp.Code != http.StatusUnprocessableEntity || p.Code != problem.ValidationFailed || len(p.Errors) == 0 {
+		t.Errorf("expected a 422 VALIDATION_FAILED with the invalid values, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestProblemsInFrench(t *testing.T) {
+	api := setupAPI(t, &bytes.Buffer{})
+
+	for header, expected := range map[string]string{
+		"fr-FR":                  "La commande 12 n'existe pas",
+		"de, fr;q=0.5, en;q=0.4": "La commande 12 n'existe pas",
+		"en-GB, fr;q=0.8":        "Order 12 doesn't exist",
+		"de":                     "Order 12 doesn't exist",
+	} {
+		resp := api.Get("/orders/12", "Accept-Language: "+header)
+		if p := decode(t, resp.Body.Bytes()); p.Detail != expected {
+			t.Errorf("expected %q for %q, got %q", expected, header, p.Detail)
+		}
+	}
+
+	resp := api.Get("/orders/0", "Accept-Language: fr")
+	if p := decode(t, resp.Body.Bytes()); p.Title != "Données invalides" || resp.Header().Get("Content-Language") != "fr" {
+		t.Errorf("expected a French title, got %q", p.Title)
+	}
+}
@@ -0,0 +1,72 @@
+package rabbitmq
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+)
+
+// MemoryBus is an in-process EventPublisher. It builds the same messages as
+// AMQPPublisher and dispatches them to the subscribed routers before
+// returning. Like with RabbitMQ, handler errors are logged and never reach
+// the publisher.
+type MemoryBus struct {
+	exchange string
+	logger   *slog.Logger
+
+	mu      sync.RWMutex
+	routers []*EventRouter
+}
+
+// NewMemoryBus creates a MemoryBus whose messages appear to come from
+// exchange
+func NewMemoryBus(exchange string, logger *slog.Logger) *MemoryBus {
+	return &MemoryBus{exchange: exchange, logger: logger}
+}
+
+// Subscribe delivers the messages published from now on to router
+func (b *MemoryBus) Subscribe(router *EventRouter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.routers = append(b.routers, router)
+}
+
+// Publish dispatches msg to every subscribed router with a handler for its
+// routing key. Messages without an exchange get the one of the bus.
+func (b *MemoryBus) Publish(ctx context.Context, msg message.Message) {
+	if msg.Exchange == "" {
+		msg.Exchange = b.exchange
+	}
+
+	b.mu.RLock()
+	routers := b.routers
+	b.mu.RUnlock()
+
+	// Handlers outlive the publishing request, as they would with RabbitMQ
+	ctx = context.WithoutCancel(ctx)
+	for _, router := range routers {
+		if _, err := router.dispatchSafely(ctx, msg); err != nil {
+			b.logger.WarnContext(ctx, "Event handler failed", "routing_key", msg.RoutingKey, "error", err)
+		}
+	}
+}
+
+// PublishOrderEvent builds an order event and publishes it on the bus
+func (b *MemoryBus) PublishOrderEvent(ctx context.Context, eventType events.EventType, order EventOrder) error {
+	msg, err := orderEventMessage(ctx, b.exchange, eventType, order)
+	if err != nil {
+		eventsPublishedCounter.Inc(publishOutcomeLabels{RoutingKey: string(eventType), Outcome: "failure"})
+		return err
+	}
+
+	ctx, span := startPublishSpan(ctx, b.exchange, msg.RoutingKey, msg.Headers)
+	defer span.End()
+
+	b.Publish(ctx, msg)
+	eventsPublishedCounter.Inc(publishOutcomeLabels{RoutingKey: msg.RoutingKey, Outcome: "success"})
+	return nil
+}
@@ -0,0 +1,57 @@
+package rabbitmq_test
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+)
+
+func TestMemoryBusPublishOrderEvent(t *testing.T) {
+	bus := rabbitmq.NewMemoryBus("events", slog.New(slog.DiscardHandler))
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+	bus.Subscribe(router)
+
+	var received events.OrderEvent
+	var raw []byte
+	router.RegisterHandler("order.*", func(ctx context.Context, msg message.Message) error {
+		if msg.Exchange != "events" {
+			t.Errorf("expected exchange events, got %q", msg.Exchange)
+		}
+		raw = msg.Body
+		return json.Unmarshal(msg.Body, &received)
+	})
+	router.RegisterHandler("order.deleted", func(ctx context.Context, msg message.Message) error {
+		panic("handler panics are recovered")
+	})
+
+	order := rabbitmq.EventOrder{
+		SimplifiedOrder: events.SimplifiedOrder{OrderID: 4, CustomerID: 2, ProductIDs: []uint{7}},
+		Lines:           []rabbitmq.EventOrderLine{{ProductID: 7, Quantity: 3}},
+	}
+	if err := bus.PublishOrderEvent(context.Background(), events.OrderCreated, order); err != nil {
+		t.Fatalf("expected event to be published, got %v", err)
+	}
+
+	if received.Type != events.OrderCreated || received.Order.OrderID != 4 || len(received.Order.ProductIDs) != 1 {
+		t.Errorf("expected the order.created event to be delivered, got %+v", received)
+	}
+
+	// The body is still an events.OrderEvent to consumers not knowing the
+	// added fields
+	var body struct{ Order map[string]any }
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatal(err)
+	}
+	if lines, ok := body.Order["lines"].([]any); !ok || len(lines) != 1 {
+		t.Errorf("expected the lines along with the order, got %v", body.Order)
+	}
+
+	if err := bus.PublishOrderEvent(context.Background(), events.OrderDeleted, order); err != nil {
+		t.Errorf("expected handler failures not to reach the publisher, got %v", err)
+	}
+}
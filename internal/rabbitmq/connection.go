@@ -1,27 +1,28 @@
 package rabbitmq
 
 import (
-	"log"
-	"os"
+	"fmt"
 
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func Connect() (*amqp.Connection, *amqp.Channel) {
-	dsn := os.Getenv("RABBIT_DSN")
-
-	conn, err := amqp.Dial(dsn)
+// Connect opens a connection and a channel in confirm mode to the broker
+// and declares the exchange events go through
+func Connect(cfg config.RabbitMQ) (*amqp.Connection, *amqp.Channel, error) {
+	conn, err := amqp.Dial(cfg.DSN)
 	if err != nil {
-		log.Fatalf("Erreur de connexion à RabbitMQ : %v", err)
+		return nil, nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
 	ch, err := conn.Channel()
 	if err != nil {
-		log.Fatalf("Erreur ouverture channel : %v", err)
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to open RabbitMQ channel: %w", err)
 	}
 
 	err = ch.ExchangeDeclare(
-		"events",
+		cfg.Exchange,
 		"topic",
 		true,
 		false,
@@ -30,8 +31,15 @@ func Connect() (*amqp.Connection, *amqp.Channel) {
 		nil,
 	)
 	if err != nil {
-		log.Fatalf("Erreur déclaration exchange : %v", err)
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	// Have the broker confirm every publish so failures can be reported
+	if err := ch.Confirm(false); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
 	}
 
-	return conn, ch
+	return conn, ch, nil
 }
@@ -1,23 +1,53 @@
 package rabbitmq
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
 
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
+	"github.com/go-chi/metrics"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-// EventHandler is a function type that processes RabbitMQ events
-type EventHandler func(body []byte) error
+const (
+	// maxStackTraceHeader bounds the stack trace stored in dead-letter headers
+	maxStackTraceHeader = 8 * 1024
+)
+
+var (
+	panicsCounter = metrics.CounterWith[panicLabels]("event_handler_panics_total", "Total number of panics recovered while handling events.")
+)
+
+// panicLabels defines labels for the recovered panics counter
+type panicLabels struct {
+	RoutingKey string `label:"routing_key"`
+}
+
+// EventHandler is a function type that processes RabbitMQ events.
+// The context is cancelled when the consumer shuts down or when a
+// timeout middleware expires.
+type EventHandler func(ctx context.Context, msg message.Message) error
 
 // EventRouter routes events to specific handlers based on routing keys
 type EventRouter struct {
-	handlers map[string]EventHandler
+	handlers    map[string]EventHandler
+	middlewares []Middleware
+	logger      *slog.Logger
 }
 
 // NewEventRouter creates a new event router
-func NewEventRouter() *EventRouter {
+func NewEventRouter(logger *slog.Logger) *EventRouter {
 	return &EventRouter{
 		handlers: make(map[string]EventHandler),
+		logger:   logger,
 	}
 }
 
@@ -26,42 +56,152 @@ func (r *EventRouter) RegisterHandler(routingKey string, handler EventHandler) {
 	r.handlers[routingKey] = handler
 }
 
-// handleMessage routes the message to the appropriate handler
-func (r *EventRouter) handleMessage(d amqp.Delivery) {
-	log.Printf("Received message with routing key: %s", d.RoutingKey)
+// Use appends middlewares to the chain wrapping every handler.
+// The first middleware registered is the outermost one.
+func (r *EventRouter) Use(middlewares ...Middleware) {
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// lookup finds the handler registered for a routing key, falling back to
+// wildcard patterns
+func (r *EventRouter) lookup(routingKey string) (EventHandler, bool) {
+	if handler, exists := r.handlers[routingKey]; exists {
+		return handler, true
+	}
 
-	// Find the appropriate handler for this routing key
-	handler, exists := r.handlers[d.RoutingKey]
+	// Check if there's a wildcard handler that matches
+	for pattern, wildcardHandler := range r.handlers {
+		if matchesWildcard(pattern, routingKey) {
+			return wildcardHandler, true
+		}
+	}
+
+	return nil, false
+}
+
+// Dispatch runs the handler matching the message routing key through the
+// middleware chain. It returns false when no handler is registered.
+func (r *EventRouter) Dispatch(ctx context.Context, msg message.Message) (bool, error) {
+	handler, exists := r.lookup(msg.RoutingKey)
 	if !exists {
-		// Check if there's a wildcard handler that matches
-		for pattern, wildcardHandler := range r.handlers {
-			if matchesWildcard(pattern, d.RoutingKey) {
-				handler = wildcardHandler
-				exists = true
-				break
-			}
+		return false, nil
+	}
+
+	return true, Chain(handler, r.middlewares...)(ctx, msg)
+}
+
+// dispatchSafely calls Dispatch and turns any panic escaping the middleware
+// chain into a *PanicError
+func (r *EventRouter) dispatchSafely(ctx context.Context, msg message.Message) (exists bool, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			exists = true
+			err = &PanicError{Value: v, Stack: debug.Stack()}
 		}
+	}()
+
+	return r.Dispatch(ctx, msg)
+}
+
+// handleMessage routes the message to the appropriate handler
+func (r *EventRouter) handleMessage(ctx context.Context, ch deadLetterPublisher, d amqp.Delivery) {
+	exists, err := r.dispatchSafely(ctx, message.FromDelivery(d))
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		r.handlePanic(ch, d, panicErr)
+		return
+	}
+
+	var validationErr *schema.ValidationError
+	if errors.As(err, &validationErr) {
+		r.handleInvalid(ch, d, validationErr)
+		return
 	}
 
 	if !exists {
-		log.Printf("No handler registered for routing key: %s", d.RoutingKey)
+		r.logger.Warn("No handler registered for routing key", "routing_key", d.RoutingKey)
 		// Acknowledge the message to remove it from the queue
-		d.Ack(false)
+		r.ack(d)
 		return
 	}
 
-	// Process the message with the handler
-	err := handler(d.Body)
 	if err != nil {
-		log.Printf("Error processing message: %v", err)
+		r.logger.Warn("Error processing message", "routing_key", d.RoutingKey, "error", err)
 		// You might want to implement retries or dead letter queue here
 		// For now, we'll just acknowledge the message to remove it from the queue
-		d.Ack(false)
+		r.ack(d)
 		return
 	}
 
 	// Successfully processed the message, acknowledge it
-	d.Ack(false)
+	r.ack(d)
+}
+
+// ack acknowledges a delivery, logging when the broker can't be told
+func (r *EventRouter) ack(d amqp.Delivery) {
+	if err := d.Ack(false); err != nil {
+		r.logger.Warn("Failed to acknowledge message", "routing_key", d.RoutingKey, "error", err)
+	}
+}
+
+// reject rejects a delivery without requeue, logging when the broker can't
+// be told
+func (r *EventRouter) reject(d amqp.Delivery) {
+	if err := d.Nack(false, false); err != nil {
+		r.logger.Warn("Failed to reject message", "routing_key", d.RoutingKey, "error", err)
+	}
+}
+
+// handlePanic dead-letters a delivery whose handler panicked, with the
+// stack trace in a header. If the dead-letter publish fails the delivery is
+// rejected without requeue so it can't crash the consumer again.
+func (r *EventRouter) handlePanic(ch deadLetterPublisher, d amqp.Delivery, panicErr *PanicError) {
+	r.logger.Error("Recovered panic while handling message",
+		"routing_key", d.RoutingKey,
+		"panic", fmt.Sprint(panicErr.Value),
+		"stack", string(panicErr.Stack),
+	)
+	panicsCounter.Inc(panicLabels{RoutingKey: d.RoutingKey})
+
+	stack := panicErr.Stack
+	if len(stack) > maxStackTraceHeader {
+		stack = stack[:maxStackTraceHeader]
+	}
+
+	err := publishDeadLetter(ch, d, "panic", amqp.Table{
+		HeaderPanic:      fmt.Sprint(panicErr.Value),
+		HeaderStackTrace: string(stack),
+	})
+	if err != nil {
+		r.logger.Error("Failed to dead-letter message", "routing_key", d.RoutingKey, "error", err)
+		r.reject(d)
+		return
+	}
+
+	r.ack(d)
+}
+
+// handleInvalid dead-letters a delivery that failed schema validation, with
+// the validation errors in a header
+func (r *EventRouter) handleInvalid(ch deadLetterPublisher, d amqp.Delivery, validationErr *schema.ValidationError) {
+	r.logger.Warn("Rejected invalid message",
+		"routing_key", d.RoutingKey,
+		"schema_version", validationErr.Version,
+		"errors", validationErr.Errors,
+	)
+
+	err := publishDeadLetter(ch, d, "invalid", amqp.Table{
+		HeaderSchemaVersion:    validationErr.Version,
+		HeaderValidationErrors: strings.Join(validationErr.Errors, "\n"),
+	})
+	if err != nil {
+		r.logger.Error("Failed to dead-letter message", "routing_key", d.RoutingKey, "error", err)
+		r.reject(d)
+		return
+	}
+
+	r.ack(d)
 }
 
 // matchesWildcard checks if a routing key matches a pattern with wildcards
@@ -79,8 +219,69 @@ func matchesWildcard(pattern, routingKey string) bool {
 	return pattern == routingKey
 }
 
-// StartListening sets up a consumer to listen for RabbitMQ events
-func StartListening(ch *amqp.Channel, router *EventRouter) (string, error) {
+// canceller stops a consumer on its channel, *amqp.Channel implements it
+type canceller interface {
+	Cancel(consumer string, noWait bool) error
+}
+
+// Consumer is a running RabbitMQ consumer started by StartListening
+type Consumer struct {
+	Queue string
+
+	ch     canceller
+	tag    string
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// Shutdown stops receiving new deliveries and waits for the in-flight
+// handler to return. When ctx expires first, the handler context is
+// cancelled and ctx.Err() is returned. Calling it again only waits.
+func (c *Consumer) Shutdown(ctx context.Context) error {
+	c.stopOnce.Do(func() {
+		c.stopErr = c.ch.Cancel(c.tag, false)
+		close(c.stop)
+	})
+
+	select {
+	case <-c.done:
+		c.cancel()
+		return c.stopErr
+	case <-ctx.Done():
+		c.cancel()
+		return ctx.Err()
+	}
+}
+
+// Check reports an error once the consumer has stopped processing
+// deliveries, whether it was shut down or its channel was closed
+func (c *Consumer) Check(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return errors.New("consumer stopped")
+	default:
+		return nil
+	}
+}
+
+// StartListening sets up a consumer to listen for RabbitMQ events on the
+// configured exchange.
+// Handlers receive a context derived from ctx, so cancelling it signals
+// in-flight handlers to stop.
+func StartListening(ctx context.Context, ch *amqp.Channel, cfg config.RabbitMQ, router *EventRouter) (*Consumer, error) {
+	if err := declareDeadLetter(ch); err != nil {
+		return nil, err
+	}
+
+	// Limit unacknowledged deliveries so shutdown only has a few to drain
+	if err := ch.Qos(cfg.Prefetch, 0, false); err != nil {
+		return nil, err
+	}
+
 	// Declare a queue with a random name
 	q, err := ch.QueueDeclare(
 		"",    // name (empty for auto-generated name)
@@ -91,7 +292,7 @@ func StartListening(ch *amqp.Channel, router *EventRouter) (string, error) {
 		nil,   // arguments
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Bind the queue to the exchange with routing keys
@@ -99,42 +300,45 @@ func StartListening(ch *amqp.Channel, router *EventRouter) (string, error) {
 		if routingKey == "#" {
 			// Special case: bind to all messages
 			err = ch.QueueBind(
-				q.Name,   // queue name
-				"#",      // routing key
-				"events", // exchange
-				false,    // no-wait
-				nil,      // arguments
+				q.Name,       // queue name
+				"#",          // routing key
+				cfg.Exchange, // exchange
+				false,        // no-wait
+				nil,          // arguments
 			)
 		} else if len(routingKey) > 0 && routingKey[len(routingKey)-1] == '*' {
 			// Wildcard binding
 			bindingKey := routingKey[:len(routingKey)-1] + "#"
 			err = ch.QueueBind(
-				q.Name,     // queue name
-				bindingKey, // routing key with AMQP wildcard
-				"events",   // exchange
-				false,      // no-wait
-				nil,        // arguments
+				q.Name,       // queue name
+				bindingKey,   // routing key with AMQP wildcard
+				cfg.Exchange, // exchange
+				false,        // no-wait
+				nil,          // arguments
 			)
 		} else {
 			// Standard binding
 			err = ch.QueueBind(
-				q.Name,     // queue name
-				routingKey, // routing key
-				"events",   // exchange
-				false,      // no-wait
-				nil,        // arguments
+				q.Name,       // queue name
+				routingKey,   // routing key
+				cfg.Exchange, // exchange
+				false,        // no-wait
+				nil,          // arguments
 			)
 		}
 
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
+	hostname, _ := os.Hostname()
+	tag := fmt.Sprintf("orders-%s-%d", hostname, os.Getpid())
+
 	// Start consuming messages
 	msgs, err := ch.Consume(
 		q.Name, // queue
-		"",     // consumer
+		tag,    // consumer
 		false,  // auto-ack (false means manual acknowledgment)
 		false,  // exclusive
 		false,  // no-local
@@ -142,17 +346,39 @@ func StartListening(ch *amqp.Channel, router *EventRouter) (string, error) {
 		nil,    // args
 	)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	handlerCtx, cancel := context.WithCancel(ctx)
+	consumer := &Consumer{
+		Queue:  q.Name,
+		ch:     ch,
+		tag:    tag,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
 	}
 
 	// Start a goroutine to process messages
 	go func() {
-		for d := range msgs {
-			router.handleMessage(d)
+		defer close(consumer.done)
+
+		for {
+			select {
+			case <-consumer.stop:
+				// Unacknowledged deliveries are requeued when the channel closes
+				router.logger.Info("RabbitMQ consumer stopped")
+				return
+			case d, ok := <-msgs:
+				if !ok {
+					router.logger.Warn("RabbitMQ consumer channel closed")
+					return
+				}
+				router.handleMessage(handlerCtx, ch, d)
+			}
 		}
-		log.Println("RabbitMQ consumer channel closed")
 	}()
 
-	log.Printf("Started listening for events on queue %s", q.Name)
-	return q.Name, nil
+	router.logger.Info("Started listening for events", "queue", q.Name)
+	return consumer, nil
 }
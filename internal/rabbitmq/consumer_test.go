@@ -0,0 +1,238 @@
+package rabbitmq_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestDispatchMiddlewareOrder(t *testing.T) {
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+
+	var calls []string
+	trace := func(name string) rabbitmq.Middleware {
+		return func(next rabbitmq.EventHandler) rabbitmq.EventHandler {
+			return func(ctx context.Context, msg message.Message) error {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+
+	router.Use(trace("outer"), trace("inner"))
+	router.RegisterHandler("customer.created", func(ctx context.Context, msg message.Message) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	exists, err := router.Dispatch(context.Background(), message.Message{RoutingKey: "customer.created"})
+	if !exists || err != nil {
+		t.Fatalf("expected handler to run without error, got exists=%v err=%v", exists, err)
+	}
+
+	expected := []string{"outer", "inner", "handler"}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected calls %v, got %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("expected calls %v, got %v", expected, calls)
+			break
+		}
+	}
+}
+
+func TestDispatchWildcard(t *testing.T) {
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+
+	var received string
+	router.RegisterHandler("product.*", func(ctx context.Context, msg message.Message) error {
+		received = msg.RoutingKey
+		return nil
+	})
+
+	exists, _ := router.Dispatch(context.Background(), message.Message{RoutingKey: "product.updated"})
+	if !exists || received != "product.updated" {
+		t.Errorf("expected wildcard handler to receive product.updated, got %q", received)
+	}
+
+	exists, _ = router.Dispatch(context.Background(), message.Message{RoutingKey: "customer.updated"})
+	if exists {
+		t.Error("expected no handler for customer.updated")
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+	router.Use(rabbitmq.Recover())
+	router.RegisterHandler("order.created", func(ctx context.Context, msg message.Message) error {
+		panic("boom")
+	})
+
+	_, err := router.Dispatch(context.Background(), message.Message{RoutingKey: "order.created"})
+
+	var panicErr *rabbitmq.PanicError
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected PanicError, got %v", err)
+	}
+	if panicErr.Value != "boom" || len(panicErr.Stack) == 0 {
+		t.Errorf("expected panic value and stack, got %v", panicErr)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+	router.Use(rabbitmq.Timeout(10 * time.Millisecond))
+	router.RegisterHandler("order.created", func(ctx context.Context, msg message.Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	_, err := router.Dispatch(context.Background(), message.Message{RoutingKey: "order.created"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+// channel fakes an *amqp.Channel, counting consumer cancellations and
+// recording publishings, which fail with err
+type channel struct {
+	cancels   int
+	published []publishing
+	err       error
+}
+
+// publishing is a message published on a channel
+type publishing struct {
+	exchange, key string
+	msg           amqp.Publishing
+}
+
+func (c *channel) Cancel(consumer string, noWait bool) error {
+	c.cancels++
+	return nil
+}
+
+func (c *channel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.published = append(c.published, publishing{exchange: exchange, key: key, msg: msg})
+	return nil
+}
+
+// acknowledger records how a delivery was settled
+type acknowledger struct {
+	acked, nacked, requeued bool
+}
+
+func (a *acknowledger) Ack(tag uint64, multiple bool) error {
+	a.acked = true
+	return nil
+}
+
+func (a *acknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+
+func (a *acknowledger) Reject(tag uint64, requeue bool) error {
+	a.nacked, a.requeued = true, requeue
+	return nil
+}
+
+// deliver hands a customer.created delivery to router and returns how it
+// was settled
+func deliver(router *rabbitmq.EventRouter, ch *channel) *acknowledger {
+	ack := &acknowledger{}
+	router.HandleMessage(context.Background(), ch, amqp.Delivery{
+		Acknowledger: ack,
+		DeliveryTag:  1,
+		Exchange:     "events",
+		RoutingKey:   "customer.created",
+		Body:         []byte(`{"customer":{"id":1}}`),
+	})
+	return ack
+}
+
+func TestHandleMessagePanic(t *testing.T) {
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+	router.RegisterHandler("customer.created", func(ctx context.Context, msg message.Message) error {
+		panic("boom")
+	})
+
+	ch := &channel{}
+	ack := deliver(router, ch)
+	if len(ch.published) != 1 {
+		t.Fatalf("expected the message to be dead-lettered, got %+v", ch.published)
+	}
+	dead := ch.published[0]
+	if dead.exchange != "events.dead-letter" || dead.key != "customer.created" || string(dead.msg.Body) != `{"customer":{"id":1}}` {
+		t.Errorf("expected the message on the dead-letter exchange as is, got %+v", dead)
+	}
+	headers := dead.msg.Headers
+	if headers[rabbitmq.HeaderDeadLetterReason] != "panic" || headers[rabbitmq.HeaderPanic] != "boom" ||
+		headers[rabbitmq.HeaderStackTrace] == "" || headers[rabbitmq.HeaderOriginalExchange] != "events" {
+		t.Errorf("expected the panic in the headers, got %v", headers)
+	}
+	if !ack.acked || ack.requeued {
+		t.Errorf("expected the message to leave the queue without being requeued, got %+v", ack)
+	}
+
+	// Without a dead-letter exchange, the message is dropped rather than
+	// crashing the consumer again
+	ch = &channel{err: errors.New("channel closed")}
+	if ack := deliver(router, ch); !ack.nacked || ack.requeued {
+		t.Errorf("expected the message to be rejected without requeue, got %+v", ack)
+	}
+}
+
+func TestConsumerShutdownTwice(t *testing.T) {
+	ch := &channel{}
+	consumer := rabbitmq.NewIdleConsumer(ch)
+
+	for i := range 2 {
+		if err := consumer.Shutdown(context.Background()); err != nil {
+			t.Errorf("expected shutdown %d to succeed, got %v", i, err)
+		}
+	}
+	if ch.cancels != 1 {
+		t.Errorf("expected the consumer to be cancelled once, got %d", ch.cancels)
+	}
+	if err := consumer.Check(context.Background()); err == nil {
+		t.Error("expected a stopped consumer to fail its check")
+	}
+}
+
+func TestHandleMessageInvalid(t *testing.T) {
+	router := rabbitmq.NewEventRouter(slog.New(slog.DiscardHandler))
+	router.RegisterHandler("customer.created", func(ctx context.Context, msg message.Message) error {
+		return &schema.ValidationError{EventType: "customer.created", Version: "v1", Errors: []string{"/customer/id: missing", "/type: missing"}}
+	})
+
+	ch := &channel{}
+	ack := deliver(router, ch)
+	if len(ch.published) != 1 || ch.published[0].exchange != "events.dead-letter" {
+		t.Fatalf("expected the message to be dead-lettered, got %+v", ch.published)
+	}
+	headers := ch.published[0].msg.Headers
+	if headers[rabbitmq.HeaderDeadLetterReason] != "invalid" || headers[rabbitmq.HeaderSchemaVersion] != "v1" ||
+		headers[rabbitmq.HeaderValidationErrors] != "/customer/id: missing\n/type: missing" {
+		t.Errorf("expected the validation errors in the headers, got %v", headers)
+	}
+	if !ack.acked || ack.requeued {
+		t.Errorf("expected the message to leave the queue without being requeued, got %+v", ack)
+	}
+
+	ch = &channel{err: errors.New("channel closed")}
+	if ack := deliver(router, ch); !ack.nacked || ack.requeued {
+		t.Errorf("expected the message to be rejected without requeue, got %+v", ack)
+	}
+}
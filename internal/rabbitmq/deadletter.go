@@ -0,0 +1,98 @@
+package rabbitmq
+
+import (
+	"context"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// deadLetterExchange receives messages the consumer gave up on
+	deadLetterExchange = "events.dead-letter"
+	// deadLetterQueue keeps dead-lettered messages for inspection
+	deadLetterQueue = "orders.dead-letter"
+)
+
+// Headers added to dead-lettered messages
+const (
+	HeaderDeadLetterReason   = "x-dead-letter-reason"
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+	HeaderPanic              = "x-panic"
+	HeaderStackTrace         = "x-stack-trace"
+	HeaderSchemaVersion      = "x-schema-version"
+	HeaderValidationErrors   = "x-validation-errors"
+)
+
+// declareDeadLetter declares the dead-letter exchange and its queue
+func declareDeadLetter(ch *amqp.Channel) error {
+	err := ch.ExchangeDeclare(
+		deadLetterExchange,
+		"topic",
+		true,  // durable
+		false, // auto-deleted
+		false, // internal
+		false, // no-wait
+		nil,
+	)
+	if err != nil {
+		return err
+	}
+
+	q, err := ch.QueueDeclare(
+		deadLetterQueue,
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	return ch.QueueBind(q.Name, "#", deadLetterExchange, false, nil)
+}
+
+// deadLetterPublisher publishes dead-lettered messages, *amqp.Channel
+// implements it
+type deadLetterPublisher interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// publishDeadLetter republishes a delivery to the dead-letter exchange with
+// the reason and extra headers, keeping its original routing key
+func publishDeadLetter(ch deadLetterPublisher, d amqp.Delivery, reason string, extra amqp.Table) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	headers[HeaderDeadLetterReason] = reason
+	headers[HeaderOriginalExchange] = d.Exchange
+	headers[HeaderOriginalRoutingKey] = d.RoutingKey
+
+	return ch.PublishWithContext(
+		ctx,
+		deadLetterExchange,
+		d.RoutingKey,
+		false, // mandatory
+		false, // immediate
+		amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			MessageId:     d.MessageId,
+			CorrelationId: d.CorrelationId,
+			Timestamp:     d.Timestamp,
+			Type:          d.Type,
+			DeliveryMode:  amqp.Persistent,
+			Body:          d.Body,
+		},
+	)
+}
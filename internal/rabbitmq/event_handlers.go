@@ -1,18 +1,36 @@
 package rabbitmq
 
 import (
+	"log/slog"
+	"time"
+
 	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/event_handlers"
-	"gorm.io/gorm"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
 )
 
-// SetupEventHandlers configures handlers for different event types
-func SetupEventHandlers(dbConn *gorm.DB) *EventRouter {
-	router := NewEventRouter()
+// SetupEventHandlers configures handlers for different event types. The
+// answers of the Products service to stock reservations are passed to
+// reservations. Each handler is given handlerTimeout to complete.
+func SetupEventHandlers(repos repository.Repositories, reservations event_handlers.StockReservations, registry *schema.Registry, handlerTimeout time.Duration, logger *slog.Logger) *EventRouter {
+	router := NewEventRouter(logger)
+
+	// Middlewares applied to every handler, outermost first
+	router.Use(
+		Tracing(),
+		Correlation(),
+		Logging(logger),
+		Metrics(),
+		Recover(),
+		Validate(registry),
+		Timeout(handlerTimeout),
+	)
 
 	// Initialize event handlers
-	customerHandlers := event_handlers.NewCustomerEventHandlers(dbConn)
-	productHandlers := event_handlers.NewProductEventHandlers(dbConn)
-	debugHandlers := event_handlers.NewDebugEventHandlers()
+	customerHandlers := event_handlers.NewCustomerEventHandlers(repos.Customers, logger)
+	productHandlers := event_handlers.NewProductEventHandlers(repos.Products, logger)
+	stockHandlers := event_handlers.NewStockEventHandlers(reservations, logger)
+	debugHandlers := event_handlers.NewDebugEventHandlers(logger)
 
 	// Register customer event handlers
 	router.RegisterHandler("customer.created", customerHandlers.HandleCustomerCreated)
@@ -24,6 +42,10 @@ func SetupEventHandlers(dbConn *gorm.DB) *EventRouter {
 	router.RegisterHandler("product.updated", productHandlers.HandleProductUpdated)
 	router.RegisterHandler("product.deleted", productHandlers.HandleProductDeleted)
 
+	// Register the stock reservation handlers of the order placement saga
+	router.RegisterHandler(string(event_handlers.StockReserved), stockHandlers.HandleStockReserved)
+	router.RegisterHandler(string(event_handlers.StockRejected), stockHandlers.HandleStockRejected)
+
 	// Register debug catch-all handler
 	// Useful during development, can be removed in production
 	router.RegisterHandler("#", debugHandlers.HandleAllEvents)
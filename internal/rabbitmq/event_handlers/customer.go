@@ -1,86 +1,85 @@
 package event_handlers
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"log/slog"
 
 	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
-	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
-	"gorm.io/gorm"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
 )
 
 // CustomerEventHandlers provides handlers for customer-related events
 type CustomerEventHandlers struct {
-	db *gorm.DB
+	customers repository.CustomerRepository
+	logger    *slog.Logger
 }
 
 // NewCustomerEventHandlers creates a new customer event handlers instance
-func NewCustomerEventHandlers(db *gorm.DB) *CustomerEventHandlers {
-	return &CustomerEventHandlers{db: db}
+func NewCustomerEventHandlers(customers repository.CustomerRepository, logger *slog.Logger) *CustomerEventHandlers {
+	return &CustomerEventHandlers{customers: customers, logger: logger}
 }
 
 // HandleCustomerCreated handles the customer.created event
-func (h *CustomerEventHandlers) HandleCustomerCreated(body []byte) error {
+func (h *CustomerEventHandlers) HandleCustomerCreated(ctx context.Context, msg message.Message) error {
 	var event events.CustomerEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		log.Printf("Error unmarshaling customer.created event: %v", err)
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling customer.created event", "error", err)
 		return err
 	}
 
-	log.Printf("Received customer.created event for customer %d", event.Customer.ID)
+	ctx = logging.With(ctx, "customer_id", event.Customer.ID)
+	h.logger.InfoContext(ctx, "Received customer.created event")
 
 	// Create the customer in the local database
-	customer := localModels.Customer{}
-	customer.ID = event.Customer.ID
-
-	if err := h.db.Create(&customer).Error; err != nil {
-		log.Printf("Error creating customer in DB: %v", err)
+	if err := h.customers.Create(ctx, event.Customer.ID); err != nil {
+		h.logger.ErrorContext(ctx, "Error creating customer in DB", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully created customer %d in local database", customer.ID)
+	h.logger.InfoContext(ctx, "Successfully created customer in local database")
 	return nil
 }
 
 // HandleCustomerUpdated handles the customer.updated event
-func (h *CustomerEventHandlers) HandleCustomerUpdated(body []byte) error {
+func (h *CustomerEventHandlers) HandleCustomerUpdated(ctx context.Context, msg message.Message) error {
 	var event events.CustomerEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		log.Printf("Error unmarshaling customer.updated event: %v", err)
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling customer.updated event", "error", err)
 		return err
 	}
 
-	log.Printf("Received customer.updated event for customer %d", event.Customer.ID)
+	ctx = logging.With(ctx, "customer_id", event.Customer.ID)
+	h.logger.InfoContext(ctx, "Received customer.updated event")
 
 	// Update the customer in the local database
-	customer := localModels.Customer{}
-	customer.ID = event.Customer.ID
-
-	if err := h.db.Save(&customer).Error; err != nil {
-		log.Printf("Error updating customer in DB: %v", err)
+	if err := h.customers.Save(ctx, event.Customer.ID); err != nil {
+		h.logger.ErrorContext(ctx, "Error updating customer in DB", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully updated customer %d in local database", customer.ID)
+	h.logger.InfoContext(ctx, "Successfully updated customer in local database")
 	return nil
 }
 
 // HandleCustomerDeleted handles the customer.deleted event
-func (h *CustomerEventHandlers) HandleCustomerDeleted(body []byte) error {
+func (h *CustomerEventHandlers) HandleCustomerDeleted(ctx context.Context, msg message.Message) error {
 	var event events.CustomerEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		log.Printf("Error unmarshaling customer.deleted event: %v", err)
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling customer.deleted event", "error", err)
 		return err
 	}
 
-	log.Printf("Received customer.deleted event for customer %d", event.Customer.ID)
+	ctx = logging.With(ctx, "customer_id", event.Customer.ID)
+	h.logger.InfoContext(ctx, "Received customer.deleted event")
 
 	// Delete the customer from the local database
-	if err := h.db.Delete(&localModels.Customer{}, event.Customer.ID).Error; err != nil {
-		log.Printf("Error deleting customer from DB: %v", err)
+	if err := h.customers.Delete(ctx, event.Customer.ID); err != nil {
+		h.logger.ErrorContext(ctx, "Error deleting customer from DB", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully deleted customer %d from local database", event.Customer.ID)
+	h.logger.InfoContext(ctx, "Successfully deleted customer from local database")
 	return nil
 }
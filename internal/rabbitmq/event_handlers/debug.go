@@ -1,33 +1,36 @@
 package event_handlers
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"log/slog"
 
 	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
 )
 
 // DebugEventHandlers provides handlers for debugging purposes
-type DebugEventHandlers struct{}
+type DebugEventHandlers struct {
+	logger *slog.Logger
+}
 
 // NewDebugEventHandlers creates a new debug event handlers instance
-func NewDebugEventHandlers() *DebugEventHandlers {
-	return &DebugEventHandlers{}
+func NewDebugEventHandlers(logger *slog.Logger) *DebugEventHandlers {
+	return &DebugEventHandlers{logger: logger}
 }
 
 // HandleAllEvents is a catch-all handler for debugging purposes
 // Useful during development, can be removed in production
-func (h *DebugEventHandlers) HandleAllEvents(body []byte) error {
+func (h *DebugEventHandlers) HandleAllEvents(ctx context.Context, msg message.Message) error {
 	var generic events.GenericEvent
-	if err := json.Unmarshal(body, &generic); err != nil {
-		log.Printf("Error unmarshaling generic event: %v", err)
+	if err := msg.Decode(&generic); err != nil {
 		// Don't return error here as it might be a different format
 		// Just log and continue
+		h.logger.WarnContext(ctx, "Error unmarshaling generic event", "routing_key", msg.RoutingKey, "error", err)
 	} else {
-		log.Printf("Received event of type %s", generic.Type)
+		h.logger.DebugContext(ctx, "Received event", "type", generic.Type, "routing_key", msg.RoutingKey)
 	}
 
 	// Log the raw message for debugging
-	log.Printf("Raw event: %s", string(body))
+	h.logger.DebugContext(ctx, "Raw event", "routing_key", msg.RoutingKey, "body", string(msg.Body))
 	return nil
 }
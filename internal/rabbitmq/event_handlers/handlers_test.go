@@ -0,0 +1,107 @@
+package event_handlers_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/event_handlers"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+)
+
+func newMessage(routingKey, body string) message.Message {
+	return message.Message{RoutingKey: routingKey, ContentType: "application/json", Body: []byte(body)}
+}
+
+func TestCustomerEventHandlers(t *testing.T) {
+	ctx := context.Background()
+	customers := repository.NewMemoryCustomerRepository()
+	handlers := event_handlers.NewCustomerEventHandlers(customers, slog.New(slog.DiscardHandler))
+
+	created := newMessage("customer.created", `{"type":"customer.created","customer":{"ID":3}}`)
+	if err := handlers.HandleCustomerCreated(ctx, created); err != nil {
+		t.Fatalf("expected customer to be created, got %v", err)
+	}
+	if !customers.Exists(3) {
+		t.Error("expected customer 3 to exist")
+	}
+
+	// A redelivered creation is reported
+	if err := handlers.HandleCustomerCreated(ctx, created); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	deleted := newMessage("customer.deleted", `{"type":"customer.deleted","customer":{"ID":3}}`)
+	if err := handlers.HandleCustomerDeleted(ctx, deleted); err != nil {
+		t.Fatalf("expected customer to be deleted, got %v", err)
+	}
+	if customers.Exists(3) {
+		t.Error("expected customer 3 to be deleted")
+	}
+
+	if err := handlers.HandleCustomerUpdated(ctx, newMessage("customer.updated", `not json`)); err == nil {
+		t.Error("expected an error for a malformed event")
+	}
+}
+
+func TestProductEventHandlers(t *testing.T) {
+	ctx := context.Background()
+	products := repository.NewMemoryProductRepository()
+	handlers := event_handlers.NewProductEventHandlers(products, slog.New(slog.DiscardHandler))
+
+	// An update for a product we never saw creates it
+	updated := newMessage("product.updated", `{"type":"product.updated","product":{"ID":8,"stock":2}}`)
+	if err := handlers.HandleProductUpdated(ctx, updated); err != nil {
+		t.Fatalf("expected product to be saved, got %v", err)
+	}
+	if !products.Exists(8) {
+		t.Error("expected product 8 to exist")
+	}
+
+	deleted := newMessage("product.deleted", `{"type":"product.deleted","product":{"ID":8}}`)
+	if err := handlers.HandleProductDeleted(ctx, deleted); err != nil {
+		t.Fatalf("expected product to be deleted, got %v", err)
+	}
+	if products.Exists(8) {
+		t.Error("expected product 8 to be deleted")
+	}
+}
+
+// stockReservations records the answers to stock reservations
+type stockReservations struct {
+	reserved []uint
+	rejected map[uint]string
+}
+
+func (s *stockReservations) Reserved(ctx context.Context, orderID uint) error {
+	s.reserved = append(s.reserved, orderID)
+	return nil
+}
+
+func (s *stockReservations) Rejected(ctx context.Context, orderID uint, reason string) error {
+	s.rejected[orderID] = reason
+	return nil
+}
+
+func TestStockEventHandlers(t *testing.T) {
+	ctx := context.Background()
+	reservations := &stockReservations{rejected: map[uint]string{}}
+	handlers := event_handlers.NewStockEventHandlers(reservations, slog.New(slog.DiscardHandler))
+
+	if err := handlers.HandleStockReserved(ctx, newMessage("product.stock.reserved", `{"type":"product.stock.reserved","orderId":4}`)); err != nil {
+		t.Fatal(err)
+	}
+	rejected := newMessage("product.stock.rejected", `{"type":"product.stock.rejected","orderId":5,"reason":"Out of stock","productIds":[2]}`)
+	if err := handlers.HandleStockRejected(ctx, rejected); err != nil {
+		t.Fatal(err)
+	}
+	if len(reservations.reserved) != 1 || reservations.reserved[0] != 4 || reservations.rejected[5] != "Out of stock" {
+		t.Errorf("expected order 4 to be reserved and 5 rejected, got %+v", reservations)
+	}
+
+	if err := handlers.HandleStockReserved(ctx, newMessage("product.stock.reserved", `not json`)); err == nil {
+		t.Error("expected an error for a malformed event")
+	}
+}
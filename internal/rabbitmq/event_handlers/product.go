@@ -1,86 +1,85 @@
 package event_handlers
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"log/slog"
 
 	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
-	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
-	"gorm.io/gorm"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
 )
 
 // ProductEventHandlers provides handlers for product-related events
 type ProductEventHandlers struct {
-	db *gorm.DB
+	products repository.ProductRepository
+	logger   *slog.Logger
 }
 
 // NewProductEventHandlers creates a new product event handlers instance
-func NewProductEventHandlers(db *gorm.DB) *ProductEventHandlers {
-	return &ProductEventHandlers{db: db}
+func NewProductEventHandlers(products repository.ProductRepository, logger *slog.Logger) *ProductEventHandlers {
+	return &ProductEventHandlers{products: products, logger: logger}
 }
 
 // HandleProductCreated handles the product.created event
-func (h *ProductEventHandlers) HandleProductCreated(body []byte) error {
+func (h *ProductEventHandlers) HandleProductCreated(ctx context.Context, msg message.Message) error {
 	var event events.ProductEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		log.Printf("Error unmarshaling product.created event: %v", err)
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling product.created event", "error", err)
 		return err
 	}
 
-	log.Printf("Received product.created event for product %d", event.Product.ID)
+	ctx = logging.With(ctx, "product_id", event.Product.ID)
+	h.logger.InfoContext(ctx, "Received product.created event")
 
 	// Create the product in the local database
-	product := localModels.Product{}
-	product.ID = event.Product.ID
-
-	if err := h.db.Create(&product).Error; err != nil {
-		log.Printf("Error creating product in DB: %v", err)
+	if err := h.products.Create(ctx, event.Product.ID); err != nil {
+		h.logger.ErrorContext(ctx, "Error creating product in DB", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully created product %d in local database", product.ID)
+	h.logger.InfoContext(ctx, "Successfully created product in local database")
 	return nil
 }
 
 // HandleProductUpdated handles the product.updated event
-func (h *ProductEventHandlers) HandleProductUpdated(body []byte) error {
+func (h *ProductEventHandlers) HandleProductUpdated(ctx context.Context, msg message.Message) error {
 	var event events.ProductEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		log.Printf("Error unmarshaling product.updated event: %v", err)
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling product.updated event", "error", err)
 		return err
 	}
 
-	log.Printf("Received product.updated event for product %d", event.Product.ID)
+	ctx = logging.With(ctx, "product_id", event.Product.ID)
+	h.logger.InfoContext(ctx, "Received product.updated event")
 
 	// Update the product in the local database
-	product := localModels.Product{}
-	product.ID = event.Product.ID
-
-	if err := h.db.Save(&product).Error; err != nil {
-		log.Printf("Error updating product in DB: %v", err)
+	if err := h.products.Save(ctx, event.Product.ID); err != nil {
+		h.logger.ErrorContext(ctx, "Error updating product in DB", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully updated product %d in local database", product.ID)
+	h.logger.InfoContext(ctx, "Successfully updated product in local database")
 	return nil
 }
 
 // HandleProductDeleted handles the product.deleted event
-func (h *ProductEventHandlers) HandleProductDeleted(body []byte) error {
+func (h *ProductEventHandlers) HandleProductDeleted(ctx context.Context, msg message.Message) error {
 	var event events.ProductEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		log.Printf("Error unmarshaling product.deleted event: %v", err)
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling product.deleted event", "error", err)
 		return err
 	}
 
-	log.Printf("Received product.deleted event for product %d", event.Product.ID)
+	ctx = logging.With(ctx, "product_id", event.Product.ID)
+	h.logger.InfoContext(ctx, "Received product.deleted event")
 
 	// Delete the product from the local database
-	if err := h.db.Delete(&localModels.Product{}, event.Product.ID).Error; err != nil {
-		log.Printf("Error deleting product from DB: %v", err)
+	if err := h.products.Delete(ctx, event.Product.ID); err != nil {
+		h.logger.ErrorContext(ctx, "Error deleting product from DB", "error", err)
 		return err
 	}
 
-	log.Printf("Successfully deleted product %d from local database", event.Product.ID)
+	h.logger.InfoContext(ctx, "Successfully deleted product from local database")
 	return nil
 }
@@ -0,0 +1,82 @@
+package event_handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+)
+
+// Answers of the Products service to the reservation of the stock of an
+// order, which order.created requests
+const (
+	StockReserved events.EventType = "product.stock.reserved"
+	StockRejected events.EventType = "product.stock.rejected"
+)
+
+// StockEvent is the answer of the Products service to a stock reservation
+type StockEvent struct {
+	Type    events.EventType `json:"type"`
+	OrderID uint             `json:"orderId"`
+	// Reason and ProductIDs tell why the stock was rejected
+	Reason     string    `json:"reason,omitempty"`
+	ProductIDs []uint    `json:"productIds,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// StockReservations move orders on once their stock is reserved or
+// rejected, the reservation saga implements it
+type StockReservations interface {
+	Reserved(ctx context.Context, orderID uint) error
+	Rejected(ctx context.Context, orderID uint, reason string) error
+}
+
+// StockEventHandlers provides handlers for stock reservation events
+type StockEventHandlers struct {
+	reservations StockReservations
+	logger       *slog.Logger
+}
+
+// NewStockEventHandlers creates a new stock event handlers instance
+func NewStockEventHandlers(reservations StockReservations, logger *slog.Logger) *StockEventHandlers {
+	return &StockEventHandlers{reservations: reservations, logger: logger}
+}
+
+// HandleStockReserved handles the product.stock.reserved event
+func (h *StockEventHandlers) HandleStockReserved(ctx context.Context, msg message.Message) error {
+	var event StockEvent
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling product.stock.reserved event", "error", err)
+		return err
+	}
+
+	ctx = logging.With(ctx, "order_id", event.OrderID)
+	h.logger.InfoContext(ctx, "Received product.stock.reserved event")
+
+	if err := h.reservations.Reserved(ctx, event.OrderID); err != nil {
+		h.logger.ErrorContext(ctx, "Error confirming order", "error", err)
+		return err
+	}
+	return nil
+}
+
+// HandleStockRejected handles the product.stock.rejected event
+func (h *StockEventHandlers) HandleStockRejected(ctx context.Context, msg message.Message) error {
+	var event StockEvent
+	if err := msg.Decode(&event); err != nil {
+		h.logger.ErrorContext(ctx, "Error unmarshaling product.stock.rejected event", "error", err)
+		return err
+	}
+
+	ctx = logging.With(ctx, "order_id", event.OrderID)
+	h.logger.InfoContext(ctx, "Received product.stock.rejected event", "reason", event.Reason, "product_ids", event.ProductIDs)
+
+	if err := h.reservations.Rejected(ctx, event.OrderID, event.Reason); err != nil {
+		h.logger.ErrorContext(ctx, "Error cancelling order", "error", err)
+		return err
+	}
+	return nil
+}
@@ -3,50 +3,221 @@ package rabbitmq
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/go-chi/metrics"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel/codes"
 )
 
-// PublishOrderEvent publishes a order event to RabbitMQ
-func PublishOrderEvent(ch *amqp.Channel, eventType events.EventType, order events.SimplifiedOrder) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+const (
+	// EventSource is the CloudEvents source of the events we publish
+	EventSource = "orders"
+	// OrderEventSchema identifies the version of the order event payload.
+	// Fields added since are optional, so it is still v1.
+	OrderEventSchema = "urn:paye-ton-kawa:schemas:order-event:v1"
+)
+
+const (
+	// OrderConfirmed is published when the stock of an order is reserved
+	OrderConfirmed events.EventType = "order.confirmed"
+	// OrderCancelled is published when an order is cancelled. Its lines
+	// tell the Products service which stock to release.
+	OrderCancelled events.EventType = "order.cancelled"
+)
+
+var (
+	eventsPublishedCounter  = metrics.CounterWith[publishOutcomeLabels]("events_published_total", "Total number of events published, by outcome.")
+	publishConfirmHistogram = metrics.HistogramWith[publishLabels](
+		"event_publish_confirm_duration_seconds",
+		"Time between publishing an event and the broker confirming it in seconds.",
+		[]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	)
+)
+
+// publishOutcomeLabels defines labels for the published events counter
+type publishOutcomeLabels struct {
+	RoutingKey string `label:"routing_key"`
+	Outcome    string `label:"outcome"`
+}
+
+// publishLabels defines labels for the publish confirm latency histogram
+type publishLabels struct {
+	RoutingKey string `label:"routing_key"`
+}
+
+// EventOrder is the order carried by an order event: the
+// events.SimplifiedOrder known by every service, along with the quantity of
+// each product and, for OrderCancelled, the cancellation
+type EventOrder struct {
+	events.SimplifiedOrder
+	Lines        []EventOrderLine   `json:"lines,omitempty"`
+	Cancellation *EventCancellation `json:"cancellation,omitempty"`
+}
+
+// EventOrderLine is a product of an order and the number of units ordered
+type EventOrderLine struct {
+	ProductID uint `json:"productId"`
+	Quantity  int  `json:"quantity"`
+}
+
+// EventCancellation tells why an order was cancelled
+type EventCancellation struct {
+	Reason string `json:"reason"`
+	Note   string `json:"note,omitempty"`
+}
+
+// NewEventOrder returns order and its lines as carried by order events,
+// along with the cancellation of cancelled orders
+func NewEventOrder(order localModels.Order, lines []repository.OrderLine) EventOrder {
+	e := EventOrder{
+		SimplifiedOrder: events.SimplifiedOrder{
+			OrderID:    order.ID,
+			CustomerID: order.CustomerID,
+			ProductIDs: make([]uint, 0, len(lines)),
+		},
+		Lines: make([]EventOrderLine, 0, len(lines)),
+	}
+	for _, line := range lines {
+		e.ProductIDs = append(e.ProductIDs, line.ProductID)
+		e.Lines = append(e.Lines, EventOrderLine{ProductID: line.ProductID, Quantity: line.Quantity})
+	}
+	if order.Status == localModels.OrderCancelled {
+		e.Cancellation = &EventCancellation{Reason: string(order.CancelReason), Note: order.CancelNote}
+	}
+	return e
+}
 
-	event := events.OrderEvent{
+// orderEvent is events.OrderEvent carrying an EventOrder
+type orderEvent struct {
+	Type      events.EventType `json:"type"`
+	Order     EventOrder       `json:"order"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// EventPublisher publishes the events of the service
+type EventPublisher interface {
+	// PublishOrderEvent publishes an order event. The correlation ID and
+	// trace context carried by ctx go along with it.
+	PublishOrderEvent(ctx context.Context, eventType events.EventType, order EventOrder) error
+}
+
+// orderEventMessage builds the message of an order event as a CloudEvent in
+// binary content mode. The body keeps the legacy events.OrderEvent format so
+// consumers that don't read the CloudEvents headers keep working.
+func orderEventMessage(ctx context.Context, exchange string, eventType events.EventType, order EventOrder) (message.Message, error) {
+	ce := message.NewCloudEvent(
+		EventSource,
+		string(eventType),
+		strconv.FormatUint(uint64(order.OrderID), 10),
+		OrderEventSchema,
+	)
+
+	event := orderEvent{
 		Type:      eventType,
 		Order:     order,
-		Timestamp: time.Now(),
+		Timestamp: ce.Time,
 	}
 
 	body, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Error marshaling event: %v", err)
+		return message.Message{}, fmt.Errorf("failed to marshal %s event: %w", eventType, err)
+	}
+
+	headers := ce.BinaryHeaders()
+	requestID := correlation.ID(ctx)
+	if requestID != "" {
+		headers[correlation.AMQPHeaderRequestID] = requestID
+	}
+
+	return message.Message{
+		// Use a routing key based on the event type
+		RoutingKey:    string(eventType),
+		Exchange:      exchange,
+		Headers:       headers,
+		Body:          body,
+		ContentType:   ce.DataContentType,
+		MessageID:     ce.ID,
+		CorrelationID: requestID,
+		Type:          ce.Type,
+		Timestamp:     ce.Time,
+	}, nil
+}
+
+// AMQPPublisher is an EventPublisher sending to a RabbitMQ exchange
+type AMQPPublisher struct {
+	ch       *amqp.Channel
+	exchange string
+}
+
+// NewAMQPPublisher creates an AMQPPublisher sending to exchange over ch
+func NewAMQPPublisher(ch *amqp.Channel, exchange string) *AMQPPublisher {
+	return &AMQPPublisher{ch: ch, exchange: exchange}
+}
+
+// PublishOrderEvent publishes a order event to RabbitMQ. The correlation
+// ID carried by ctx and the trace context of the publish span are written
+// to the message headers. On a channel in confirm mode it waits for the
+// broker to confirm the message.
+func (p *AMQPPublisher) PublishOrderEvent(ctx context.Context, eventType events.EventType, order EventOrder) error {
+	// Don't let a cancelled request abort the publish, only the timeout
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	msg, err := orderEventMessage(ctx, p.exchange, eventType, order)
+	if err != nil {
 		return err
 	}
+	routingKey := msg.RoutingKey
 
-	// Use a routing key based on the event type
-	routingKey := string(eventType)
+	ctx, span := startPublishSpan(ctx, p.exchange, routingKey, msg.Headers)
+	defer span.End()
 
-	err = ch.PublishWithContext(
+	start := time.Now()
+	confirmation, err := p.ch.PublishWithDeferredConfirmWithContext(
 		ctx,
-		"events", // exchange
+		p.exchange,
 		routingKey,
 		false, // mandatory
 		false, // immediate
 		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        body,
+			Headers:       msg.Headers,
+			ContentType:   msg.ContentType,
+			MessageId:     msg.MessageID,
+			CorrelationId: msg.CorrelationID,
+			Timestamp:     msg.Timestamp,
+			Type:          msg.Type,
+			AppId:         EventSource,
+			Body:          msg.Body,
 		},
 	)
 
+	// Wait for the broker to confirm it took the message, when the channel
+	// is in confirm mode
+	if err == nil && confirmation != nil {
+		var acked bool
+		acked, err = confirmation.WaitContext(ctx)
+		publishConfirmHistogram.Observe(time.Since(start).Seconds(), publishLabels{RoutingKey: routingKey})
+		if err == nil && !acked {
+			err = errors.New("broker rejected the message")
+		}
+	}
+
 	if err != nil {
-		log.Printf("Error publishing message: %v", err)
-		return err
+		eventsPublishedCounter.Inc(publishOutcomeLabels{RoutingKey: routingKey, Outcome: "failure"})
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("failed to publish %s event: %w", eventType, err)
 	}
 
-	log.Printf("Published %s event for order %d", eventType, order.OrderID)
+	eventsPublishedCounter.Inc(publishOutcomeLabels{RoutingKey: routingKey, Outcome: "success"})
 	return nil
 }
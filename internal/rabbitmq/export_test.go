@@ -0,0 +1,30 @@
+package rabbitmq
+
+import (
+	"context"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// HandleMessage handles a delivery as the consumer does, dead-lettering on
+// ch
+func (r *EventRouter) HandleMessage(ctx context.Context, ch deadLetterPublisher, d amqp.Delivery) {
+	r.handleMessage(ctx, ch, d)
+}
+
+// NewIdleConsumer returns a consumer cancelled on ch which receives no
+// deliveries, for testing its shutdown
+func NewIdleConsumer(ch canceller) *Consumer {
+	c := &Consumer{
+		ch:     ch,
+		tag:    "test",
+		cancel: func() {},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(c.done)
+		<-c.stop
+	}()
+	return c
+}
@@ -0,0 +1,169 @@
+package message
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// SpecVersion is the CloudEvents specification version we emit
+	SpecVersion = "1.0"
+	// StructuredContentType marks a message carrying a CloudEvent in structured mode
+	StructuredContentType = "application/cloudevents+json"
+
+	// headerPrefix is the CloudEvents AMQP binding prefix for attribute headers
+	headerPrefix = "cloudEvents:"
+	// altHeaderPrefix is the alternative prefix accepted from other producers
+	altHeaderPrefix = "cloudEvents_"
+)
+
+// CloudEvent holds the CloudEvents 1.0 attributes of an event
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time,omitzero"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      string          `json:"data_base64,omitempty"`
+}
+
+// NewCloudEvent creates a CloudEvent with a unique ID and the current time
+func NewCloudEvent(source, eventType, subject, dataSchema string) CloudEvent {
+	return CloudEvent{
+		SpecVersion:     SpecVersion,
+		ID:              NewID(),
+		Source:          source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		DataSchema:      dataSchema,
+	}
+}
+
+// BinaryHeaders returns the AMQP headers carrying the event attributes in
+// binary content mode, where the message body is the event data
+func (e CloudEvent) BinaryHeaders() amqp.Table {
+	headers := amqp.Table{
+		headerPrefix + "specversion": e.SpecVersion,
+		headerPrefix + "id":          e.ID,
+		headerPrefix + "source":      e.Source,
+		headerPrefix + "type":        e.Type,
+		headerPrefix + "time":        e.Time.Format(time.RFC3339Nano),
+	}
+	if e.Subject != "" {
+		headers[headerPrefix+"subject"] = e.Subject
+	}
+	if e.DataSchema != "" {
+		headers[headerPrefix+"dataschema"] = e.DataSchema
+	}
+	return headers
+}
+
+// CloudEvent extracts the CloudEvent carried by the message, either in
+// structured mode (JSON envelope) or binary mode (attribute headers).
+// It returns false for legacy messages that carry neither.
+func (m Message) CloudEvent() (CloudEvent, bool, error) {
+	if m.isStructured() {
+		var event CloudEvent
+		if err := json.Unmarshal(m.Body, &event); err != nil {
+			return CloudEvent{}, true, fmt.Errorf("invalid structured CloudEvent: %w", err)
+		}
+		if event.DataBase64 != "" {
+			data, err := base64.StdEncoding.DecodeString(event.DataBase64)
+			if err != nil {
+				return CloudEvent{}, true, fmt.Errorf("invalid CloudEvent data_base64: %w", err)
+			}
+			event.Data = data
+		}
+		return event, true, nil
+	}
+
+	specVersion := m.ceHeader("specversion")
+	if specVersion == "" {
+		return CloudEvent{}, false, nil
+	}
+
+	event := CloudEvent{
+		SpecVersion:     specVersion,
+		ID:              m.ceHeader("id"),
+		Source:          m.ceHeader("source"),
+		Type:            m.ceHeader("type"),
+		Subject:         m.ceHeader("subject"),
+		DataContentType: m.ContentType,
+		DataSchema:      m.ceHeader("dataschema"),
+		Data:            m.Body,
+	}
+	if t := m.ceHeader("time"); t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return CloudEvent{}, true, fmt.Errorf("invalid CloudEvent time: %w", err)
+		}
+		event.Time = parsed
+	}
+
+	return event, true, nil
+}
+
+// Data returns the event payload, unwrapping CloudEvents envelopes so
+// legacy and CloudEvents messages can be decoded the same way
+func (m Message) Data() ([]byte, error) {
+	event, ok, err := m.CloudEvent()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return m.Body, nil
+	}
+	return event.Data, nil
+}
+
+// Decode unmarshals the event payload into v
+func (m Message) Decode(v any) error {
+	data, err := m.Data()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// isStructured reports whether the message uses structured content mode
+func (m Message) isStructured() bool {
+	mediaType, _, err := mime.ParseMediaType(m.ContentType)
+	return err == nil && mediaType == StructuredContentType
+}
+
+// ceHeader reads a CloudEvents attribute header with either prefix
+func (m Message) ceHeader(attribute string) string {
+	if v := m.Header(headerPrefix + attribute); v != "" {
+		return v
+	}
+	return m.Header(altHeaderPrefix + attribute)
+}
+
+// NewID returns a random RFC 4122 version 4 UUID
+func NewID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return strings.Join([]string{
+		fmt.Sprintf("%x", b[0:4]),
+		fmt.Sprintf("%x", b[4:6]),
+		fmt.Sprintf("%x", b[6:8]),
+		fmt.Sprintf("%x", b[8:10]),
+		fmt.Sprintf("%x", b[10:16]),
+	}, "-")
+}
@@ -0,0 +1,60 @@
+package message_test
+
+import (
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+)
+
+func TestDecodeLegacyAndCloudEvents(t *testing.T) {
+	legacy := `{"type":"customer.created","customer":{"ID":7},"timestamp":"2025-01-01T00:00:00Z"}`
+
+	binaryEvent := message.NewCloudEvent("customers", "customer.created", "7", "")
+
+	cases := map[string]message.Message{
+		"legacy": {
+			ContentType: "application/json",
+			Body:        []byte(legacy),
+		},
+		"binary": {
+			ContentType: "application/json",
+			Headers:     binaryEvent.BinaryHeaders(),
+			Body:        []byte(legacy),
+		},
+		"structured": {
+			ContentType: message.StructuredContentType,
+			Body:        []byte(`{"specversion":"1.0","id":"1","source":"customers","type":"customer.created","data":` + legacy + `}`),
+		},
+	}
+
+	for name, msg := range cases {
+		t.Run(name, func(t *testing.T) {
+			var event events.CustomerEvent
+			if err := msg.Decode(&event); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if event.Customer.ID != 7 {
+				t.Errorf("expected customer 7, got %d", event.Customer.ID)
+			}
+		})
+	}
+}
+
+func TestBinaryHeadersRoundTrip(t *testing.T) {
+	event := message.NewCloudEvent("orders", "order.created", "42", "urn:test:v1")
+	msg := message.Message{ContentType: "application/json", Headers: event.BinaryHeaders()}
+
+	parsed, ok, err := msg.CloudEvent()
+	if err != nil || !ok {
+		t.Fatalf("expected a CloudEvent, got ok=%v err=%v", ok, err)
+	}
+
+	if parsed.ID != event.ID || parsed.Subject != "42" || parsed.DataSchema != "urn:test:v1" {
+		t.Errorf("unexpected attributes: %+v", parsed)
+	}
+	if !parsed.Time.Equal(event.Time) {
+		t.Errorf("expected time %v, got %v", event.Time, parsed.Time)
+	}
+}
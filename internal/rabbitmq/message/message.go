@@ -0,0 +1,55 @@
+package message
+
+import (
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Message is the envelope handed to event handlers. It carries the body
+// together with the routing key, headers and delivery metadata so handlers
+// don't need to know about amqp.Delivery.
+type Message struct {
+	RoutingKey    string
+	Exchange      string
+	Headers       amqp.Table
+	Body          []byte
+	ContentType   string
+	MessageID     string
+	CorrelationID string
+	Type          string
+	Timestamp     time.Time
+	Redelivered   bool
+	DeliveryTag   uint64
+}
+
+// FromDelivery builds a Message from a RabbitMQ delivery
+func FromDelivery(d amqp.Delivery) Message {
+	headers := d.Headers
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+
+	return Message{
+		RoutingKey:    d.RoutingKey,
+		Exchange:      d.Exchange,
+		Headers:       headers,
+		Body:          d.Body,
+		ContentType:   d.ContentType,
+		MessageID:     d.MessageId,
+		CorrelationID: d.CorrelationId,
+		Type:          d.Type,
+		Timestamp:     d.Timestamp,
+		Redelivered:   d.Redelivered,
+		DeliveryTag:   d.DeliveryTag,
+	}
+}
+
+// Header returns the string value of a header, or an empty string when the
+// header is missing or not a string
+func (m Message) Header(key string) string {
+	if v, ok := m.Headers[key].(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
+	"github.com/go-chi/metrics"
+)
+
+// Middleware wraps an EventHandler to add behaviour around it
+type Middleware func(next EventHandler) EventHandler
+
+var (
+	eventsHandledCounter   = metrics.CounterWith[eventLabels]("events_handled_total", "Total number of events processed by the consumer.")
+	eventsHandledHistogram = metrics.HistogramWith[eventLabels](
+		"event_handler_duration_seconds",
+		"Time spent in event handlers in seconds.",
+		[]float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	)
+	eventsValidatedCounter = metrics.CounterWith[validationLabels]("events_validated_total", "Total number of events checked against their JSON Schema.")
+)
+
+// eventLabels defines labels for the consumed events metrics
+type eventLabels struct {
+	RoutingKey string `label:"routing_key"`
+	Outcome    string `label:"outcome"`
+}
+
+// validationLabels defines labels for the schema validation counter
+type validationLabels struct {
+	EventType     string `label:"event_type"`
+	SchemaVersion string `label:"schema_version"`
+	Result        string `label:"result"`
+}
+
+// PanicError is returned by the Recover middleware when a handler panics
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Chain wraps handler with the given middlewares, the first one being the
+// outermost
+func Chain(handler EventHandler, middlewares ...Middleware) EventHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Correlation restores the correlation ID written by the publisher into the
+// handler context. Messages without one get the trace ID or a new ID, so
+// their log lines can still be grouped.
+func Correlation() Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) error {
+			requestID := msg.CorrelationID
+			if requestID == "" {
+				requestID = msg.Header(correlation.AMQPHeaderRequestID)
+			}
+
+			id := correlation.Resolve(ctx, requestID, msg.Header(correlation.HeaderTraceparent))
+			return next(correlation.WithID(ctx, id), msg)
+		}
+	}
+}
+
+// Logging logs every message received along with the handler outcome.
+// Records logged by handlers with the context carry the routing key.
+func Logging(logger *slog.Logger) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) error {
+			ctx = logging.With(ctx, "routing_key", msg.RoutingKey)
+			logger.DebugContext(ctx, "Received message", "message_id", msg.MessageID, "redelivered", msg.Redelivered)
+
+			start := time.Now()
+			err := next(ctx, msg)
+			if err != nil {
+				logger.WarnContext(ctx, "Error processing message", "duration", time.Since(start), "error", err)
+				return err
+			}
+
+			logger.InfoContext(ctx, "Processed message", "duration", time.Since(start))
+			return nil
+		}
+	}
+}
+
+// Metrics records handler duration and outcome per routing key
+func Metrics() Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+
+			labels := eventLabels{
+				RoutingKey: msg.RoutingKey,
+				Outcome:    "success",
+			}
+			if err != nil {
+				labels.Outcome = "error"
+			}
+
+			eventsHandledCounter.Inc(labels)
+			eventsHandledHistogram.Observe(time.Since(start).Seconds(), labels)
+
+			return err
+		}
+	}
+}
+
+// Recover turns a panic in a handler into a *PanicError
+func Recover() Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) (err error) {
+			defer func() {
+				if v := recover(); v != nil {
+					err = &PanicError{Value: v, Stack: debug.Stack()}
+				}
+			}()
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Timeout cancels the handler context after d
+func Timeout(d time.Duration) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Validate rejects messages whose payload doesn't match the JSON Schema
+// registered for their routing key with a *schema.ValidationError.
+// Routing keys without a schema are passed through.
+func Validate(registry *schema.Registry) Middleware {
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) error {
+			event, _, err := msg.CloudEvent()
+			if err != nil {
+				return &schema.ValidationError{EventType: msg.RoutingKey, Errors: []string{err.Error()}}
+			}
+
+			data, err := msg.Data()
+			if err != nil {
+				return &schema.ValidationError{EventType: msg.RoutingKey, Errors: []string{err.Error()}}
+			}
+
+			version, err := registry.Validate(msg.RoutingKey, event.DataSchema, data)
+			if errors.Is(err, schema.ErrNoSchema) {
+				return next(ctx, msg)
+			}
+
+			labels := validationLabels{
+				EventType:     msg.RoutingKey,
+				SchemaVersion: version,
+				Result:        "valid",
+			}
+			if err != nil {
+				labels.Result = "invalid"
+			}
+			eventsValidatedCounter.Inc(labels)
+
+			if err != nil {
+				return err
+			}
+			return next(ctx, msg)
+		}
+	}
+}
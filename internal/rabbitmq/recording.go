@@ -0,0 +1,50 @@
+package rabbitmq
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/correlation"
+)
+
+// RecordedEvent is an event published to a RecordingPublisher
+type RecordedEvent struct {
+	Type          events.EventType
+	Order         EventOrder
+	CorrelationID string
+}
+
+// RecordingPublisher is an EventPublisher keeping the events it is given,
+// for tests to assert on
+type RecordingPublisher struct {
+	// Err, when set, is returned by every publish and nothing is recorded
+	Err error
+
+	mu     sync.Mutex
+	events []RecordedEvent
+}
+
+func (p *RecordingPublisher) PublishOrderEvent(ctx context.Context, eventType events.EventType, order EventOrder) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Err != nil {
+		return p.Err
+	}
+	p.events = append(p.events, RecordedEvent{
+		Type:          eventType,
+		Order:         order,
+		CorrelationID: correlation.ID(ctx),
+	})
+	return nil
+}
+
+// Events returns the recorded events in publish order
+func (p *RecordingPublisher) Events() []RecordedEvent {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return slices.Clone(p.events)
+}
@@ -0,0 +1,87 @@
+package rabbitmq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq/message"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans created by this package
+const tracerName = "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+
+// headerCarrier adapts AMQP headers to the OpenTelemetry propagators
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	if v, ok := c[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// startPublishSpan starts a producer span and injects its context into headers
+func startPublishSpan(ctx context.Context, exchange, routingKey string, headers amqp.Table) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("publish %s", routingKey),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemRabbitMQ,
+			semconv.MessagingOperationTypeSend,
+			semconv.MessagingDestinationName(exchange),
+			semconv.MessagingRabbitMQDestinationRoutingKey(routingKey),
+		),
+	)
+
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(headers))
+	return ctx, span
+}
+
+// Tracing starts a consumer span for every message, continuing the trace
+// propagated in its headers
+func Tracing() Middleware {
+	tracer := otel.Tracer(tracerName)
+
+	return func(next EventHandler) EventHandler {
+		return func(ctx context.Context, msg message.Message) error {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(msg.Headers))
+
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("process %s", msg.RoutingKey),
+				trace.WithSpanKind(trace.SpanKindConsumer),
+				trace.WithAttributes(
+					semconv.MessagingSystemRabbitMQ,
+					semconv.MessagingOperationTypeProcess,
+					semconv.MessagingDestinationName(msg.Exchange),
+					semconv.MessagingRabbitMQDestinationRoutingKey(msg.RoutingKey),
+					semconv.MessagingMessageID(msg.MessageID),
+					attribute.Bool("messaging.rabbitmq.redelivered", msg.Redelivered),
+				),
+			)
+			defer span.End()
+
+			err := next(ctx, msg)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/danielgtaylor/huma/v2"
+)
+
+// Middleware limits the requests of each client with the buckets of store.
+// It runs after authentication, so clients are told apart by the principal
+// of the request, or by IP address without one. The operations listed in
+// cfg.Routes have a bucket of their own, the others share one.
+//
+// Responses carry the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, and rejected requests get a 429 with Retry-After.
+// Requests are let through when the store fails.
+func Middleware(api huma.API, store Store, cfg config.RateLimit, logger *slog.Logger) func(huma.Context, func(huma.Context)) {
+	proxies := trustedProxies(cfg.TrustedProxies)
+	return func(ctx huma.Context, next func(huma.Context)) {
+		key := client(ctx, proxies)
+		limit := Limit{Rate: cfg.Rate, Burst: cfg.Burst}
+		if route, ok := cfg.Routes[ctx.Operation().OperationID]; ok {
+			key += " " + ctx.Operation().OperationID
+			limit = Limit{Rate: route.Rate, Burst: route.Burst}
+		}
+		take(api, store, logger, ctx, next, key, limit)
+	}
+}
+
+// PreAuth limits the requests of each IP address with the buckets of store,
+// under the looser cfg.PreAuth limit. It runs before authentication, so
+// credentials can't be guessed faster than the limit and a rejected client
+// costs no lookup, while the clients sharing an address keep the limits of
+// Middleware.
+func PreAuth(api huma.API, store Store, cfg config.RateLimit, logger *slog.Logger) func(huma.Context, func(huma.Context)) {
+	proxies := trustedProxies(cfg.TrustedProxies)
+	limit := Limit{Rate: cfg.PreAuth.Rate, Burst: cfg.PreAuth.Burst}
+	return func(ctx huma.Context, next func(huma.Context)) {
+		take(api, store, logger, ctx, next, "pre-auth ip:"+clientIP(ctx, proxies), limit)
+	}
+}
+
+// reportedKey is the context key of the decision the RateLimit headers
+// report
+type reportedKey struct{}
+
+// take takes a token from the bucket of key, then either rejects the
+// request or lets it through. Of the buckets the request went through, the
+// headers report the one with the fewest tokens left.
+func take(api huma.API, store Store, logger *slog.Logger, ctx huma.Context, next func(huma.Context), key string, limit Limit) {
+	d, err := store.Take(ctx.Context(), key, limit)
+	if err != nil {
+		logger.WarnContext(ctx.Context(), "Failed to apply the rate limit, letting the request through", "error", err)
+		next(ctx)
+		return
+	}
+
+	if reported, ok := ctx.Context().Value(reportedKey{}).(Decision); !ok || !d.Allowed || d.Remaining < reported.Remaining {
+		ctx.SetHeader("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		ctx.SetHeader("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		ctx.SetHeader("RateLimit-Reset", ceilSeconds(d.Reset))
+		ctx = huma.WithValue(ctx, reportedKey{}, d)
+	}
+
+	if !d.Allowed {
+		retryAfter := ceilSeconds(d.RetryAfter)
+		ctx.SetHeader("Retry-After", retryAfter)
+		huma.WriteErr(api, ctx, http.StatusTooManyRequests, "Rate limit exceeded, retry after "+retryAfter+"s")
+		return
+	}
+
+	next(ctx)
+}
+
+// client identifies the client of a request
+func client(ctx huma.Context, proxies []netip.Prefix) string {
+	if p, ok := auth.FromContext(ctx.Context()); ok && p.Subject != auth.AnonymousSubject {
+		// API keys have a subject of their own, key:<id>
+		return "sub:" + p.Subject
+	}
+	return "ip:" + clientIP(ctx, proxies)
+}
+
+// clientIP returns the IP address of the client. Behind trusted proxies, it
+// is the last address of X-Forwarded-For not added by one of them, as the
+// client may forge the addresses before.
+func clientIP(ctx huma.Context, proxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(ctx.RemoteAddr())
+	if err != nil {
+		host = ctx.RemoteAddr()
+	}
+	if !trusted(host, proxies) {
+		return host
+	}
+
+	forwarded := strings.Split(ctx.Header("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		host = addr
+		if !trusted(addr, proxies) {
+			break
+		}
+	}
+	return host
+}
+
+// trusted tells whether addr is one of the trusted proxies
+func trusted(addr string, proxies []netip.Prefix) bool {
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, prefix := range proxies {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedProxies parses the addresses and CIDRs of the trusted proxies, the
+// configuration validated them
+func trustedProxies(proxies []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, proxy := range proxies {
+		if prefix, err := netip.ParsePrefix(proxy); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(proxy); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// ceilSeconds formats d as a whole number of seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
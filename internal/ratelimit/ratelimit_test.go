@@ -0,0 +1,201 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/auth"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/config"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/ratelimit"
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{Rate: 1, Burst: 2}
+
+	for i, remaining := range []int{1, 0} {
+		d, err := store.Take(ctx, "alice", limit)
+		if err != nil || !d.Allowed || d.Remaining != remaining {
+			t.Fatalf("expected request %d to be allowed with %d left, got %+v, %v", i, remaining, d, err)
+		}
+	}
+
+	d, _ := store.Take(ctx, "alice", limit)
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > time.Second {
+		t.Errorf("expected the empty bucket to deny for up to a second, got %+v", d)
+	}
+	if d.Reset <= time.Second || d.Reset > 2*time.Second {
+		t.Errorf("expected the bucket to refill within 2 seconds, got %v", d.Reset)
+	}
+
+	// Buckets are per key
+	if d, _ := store.Take(ctx, "bob", limit); !d.Allowed {
+		t.Error("expected another client to have its own bucket")
+	}
+
+	// Tokens are refilled over time
+	fast := ratelimit.Limit{Rate: 1000, Burst: 1}
+	store.Take(ctx, "carol", fast)
+	time.Sleep(5 * time.Millisecond)
+	if d, _ := store.Take(ctx, "carol", fast); !d.Allowed {
+		t.Error("expected the bucket to have refilled")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	_, api := humatest.New(t)
+	// Stands in for authentication
+	api.UseMiddleware(func(ctx huma.Context, next func(huma.Context)) {
+		if subject := ctx.Header("X-Subject"); subject != "" {
+			ctx = huma.WithContext(ctx, auth.WithPrincipal(ctx.Context(), &auth.Principal{Subject: subject}))
+		}
+		next(ctx)
+	})
+	api.UseMiddleware(ratelimit.Middleware(api, ratelimit.NewMemoryStore(), config.RateLimit{
+		Rate:   0.1,
+		Burst:  2,
+		Routes: map[string]config.RouteLimit{"get-orders": {Rate: 0.1, Burst: 1}},
+	}, slog.New(slog.DiscardHandler)))
+
+	for _, path := range []string{"/orders", "/orders/1"} {
+		operationID := "get-order"
+		if path == "/orders" {
+			operationID = "get-orders"
+		}
+		huma.Register(api, huma.Operation{OperationID: operationID, Method: http.MethodGet, Path: path},
+			func(ctx context.Context, input *struct{}) (*struct{}, error) {
+				return &struct{}{}, nil
+			})
+	}
+
+	resp := api.Get("/orders/1", "X-Subject: alice")
+	if resp.Code != http.StatusNoContent || resp.Header().Get("RateLimit-Limit") != "2" || resp.Header().Get("RateLimit-Remaining") != "1" {
+		t.Errorf("expected the rate limit headers, got %d %v", resp.Code, resp.Header())
+	}
+
+	// get-orders has a bucket of its own
+	if resp := api.Get("/orders", "X-Subject: alice"); resp.Code != http.StatusNoContent {
+		t.Errorf("expected get-orders not to share the default bucket, got %d", resp.Code)
+	}
+	resp = api.Get("/orders", "X-Subject: alice")
+	if resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") != "10" {
+		t.Errorf("expected 429 retrying in 10 seconds, got %d %v", resp.Code, resp.Header())
+	}
+
+	// Other principals and anonymous clients are limited separately
+	if resp := api.Get("/orders", "X-Subject: key:abc"); resp.Code != http.StatusNoContent {
+		t.Errorf("expected another principal to have its own bucket, got %d", resp.Code)
+	}
+	if resp := api.Get("/orders", "X-Subject: "+auth.AnonymousSubject); resp.Code != http.StatusNoContent {
+		t.Errorf("expected an anonymous client to be limited by IP, got %d", resp.Code)
+	}
+}
+
+// keys counts the API keys looked up, refusing them all
+type keys struct{ lookups int }
+
+func (k *keys) Verify(ctx context.Context, credentials string) (*auth.Principal, error) {
+	k.lookups++
+	return nil, errors.New("unknown API key")
+}
+
+func TestMiddlewareBeforeAuthentication(t *testing.T) {
+	_, api := humatest.New(t)
+	verifier := &keys{}
+	store := ratelimit.NewMemoryStore()
+	cfg := config.RateLimit{
+		Rate:    10,
+		Burst:   20,
+		PreAuth: config.PreAuthLimit{Rate: 0.1, Burst: 2},
+		// Requests of humatest come from 127.0.0.1, standing for the gateway
+		TrustedProxies: []string{"127.0.0.1", "192.0.2.0/24"},
+	}
+	api.UseMiddleware(ratelimit.PreAuth(api, store, cfg, slog.New(slog.DiscardHandler)))
+	api.UseMiddleware(auth.Middleware(api, nil, verifier, slog.New(slog.DiscardHandler)))
+	api.UseMiddleware(ratelimit.Middleware(api, store, cfg, slog.New(slog.DiscardHandler)))
+	huma.Register(api, huma.Operation{
+		OperationID: "get-orders",
+		Method:      http.MethodGet,
+		Path:        "/orders",
+		Security:    auth.Require(nil, []auth.Scope{auth.ScopeOrdersRead}),
+	}, func(ctx context.Context, input *struct{}) (*struct{}, error) {
+		return &struct{}{}, nil
+	})
+
+	for i, expected := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		resp := api.Get("/orders", "Authorization: ApiKey ptk_guess", "X-Forwarded-For: 203.0.113.9, 192.0.2.7")
+		if resp.Code != expected {
+			t.Errorf("expected guess %d to get %d, got %d", i, expected, resp.Code)
+		}
+	}
+	if verifier.lookups != 2 {
+		t.Errorf("expected the rejected guess not to be looked up, got %d lookups", verifier.lookups)
+	}
+
+	// Clients behind the proxy are limited separately, a forged address
+	// doesn't help
+	if resp := api.Get("/orders", "Authorization: ApiKey ptk_guess", "X-Forwarded-For: 203.0.113.10"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected another client to have its own bucket, got %d", resp.Code)
+	}
+	if resp := api.Get("/orders", "Authorization: ApiKey ptk_guess", "X-Forwarded-For: 198.51.100.1, 203.0.113.9"); resp.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a forged address to be ignored, got %d", resp.Code)
+	}
+}
+
+// setupLimitedAPI returns an API limited by cfg before and after a stand-in
+// authentication, taking the subject from the X-Subject header
+func setupLimitedAPI(t *testing.T, cfg config.RateLimit) humatest.TestAPI {
+	_, api := humatest.New(t)
+	store := ratelimit.NewMemoryStore()
+	api.UseMiddleware(ratelimit.PreAuth(api, store, cfg, slog.New(slog.DiscardHandler)))
+	api.UseMiddleware(func(ctx huma.Context, next func(huma.Context)) {
+		ctx = huma.WithContext(ctx, auth.WithPrincipal(ctx.Context(), &auth.Principal{Subject: ctx.Header("X-Subject")}))
+		next(ctx)
+	})
+	api.UseMiddleware(ratelimit.Middleware(api, store, cfg, slog.New(slog.DiscardHandler)))
+	huma.Register(api, huma.Operation{OperationID: "get-orders", Method: http.MethodGet, Path: "/orders"},
+		func(ctx context.Context, input *struct{}) (*struct{}, error) {
+			return &struct{}{}, nil
+		})
+	return api
+}
+
+func TestPreAuthSharedAddress(t *testing.T) {
+	api := setupLimitedAPI(t, config.RateLimit{Rate: 0.1, Burst: 2, PreAuth: config.PreAuthLimit{Rate: 0.1, Burst: 10}})
+
+	// API keys calling from one address each get their full burst
+	for _, subject := range []string{"key:abc", "key:def"} {
+		for i := range 2 {
+			resp := api.Get("/orders", "X-Subject: "+subject)
+			if resp.Code != http.StatusNoContent {
+				t.Fatalf("expected request %d of %s to be allowed, got %d", i, subject, resp.Code)
+			}
+			// The bucket of the key has fewer tokens left than the one of
+			// the address
+			if remaining := resp.Header().Get("RateLimit-Remaining"); remaining != strconv.Itoa(1-i) {
+				t.Errorf("expected %d requests left to %s, got %s", 1-i, subject, remaining)
+			}
+		}
+		if resp := api.Get("/orders", "X-Subject: "+subject); resp.Code != http.StatusTooManyRequests {
+			t.Errorf("expected %s to be limited after its burst, got %d", subject, resp.Code)
+		}
+	}
+
+	// The headers report the address once it has fewer tokens left
+	api = setupLimitedAPI(t, config.RateLimit{Rate: 0.1, Burst: 5, PreAuth: config.PreAuthLimit{Rate: 0.1, Burst: 1}})
+	resp := api.Get("/orders", "X-Subject: key:abc")
+	if resp.Header().Get("RateLimit-Limit") != "1" || resp.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("expected the headers of the address, got %v", resp.Header())
+	}
+	if resp := api.Get("/orders", "X-Subject: key:def"); resp.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the address to be limited, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,340 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"gorm.io/gorm"
+)
+
+// NewGorm creates the repositories backed by db
+func NewGorm(db *gorm.DB) Repositories {
+	return Repositories{
+		Orders:    NewGormOrderRepository(db),
+		Customers: NewGormCustomerRepository(db),
+		Products:  NewGormProductRepository(db),
+		APIKeys:   NewGormAPIKeyRepository(db),
+	}
+}
+
+// translate maps gorm's errors to ErrNotFound, ErrDuplicate and
+// ErrInvalidReference
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return fmt.Errorf("%w: %w", ErrDuplicate, err)
+	case errors.Is(err, gorm.ErrForeignKeyViolated) && !errors.Is(err, ErrInvalidReference):
+		return fmt.Errorf("%w: %w", ErrInvalidReference, err)
+	}
+	return err
+}
+
+// translateReference is translate, reporting foreign key violations as
+// invalid
+func translateReference(err, invalid error) error {
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return fmt.Errorf("%w: %w", invalid, err)
+	}
+	return translate(err)
+}
+
+// GormOrderRepository is an OrderRepository backed by gorm
+type GormOrderRepository struct {
+	db *gorm.DB
+}
+
+// NewGormOrderRepository creates an OrderRepository backed by db
+func NewGormOrderRepository(db *gorm.DB) *GormOrderRepository {
+	return &GormOrderRepository{db: db}
+}
+
+// read returns the session reading the orders of ctx, deleted ones included
+// when asked
+func (r *GormOrderRepository) read(ctx context.Context) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	if includesDeleted(ctx) {
+		db = db.Unscoped()
+	}
+	return db
+}
+
+func (r *GormOrderRepository) List(ctx context.Context) ([]localModels.Order, error) {
+	var orders []localModels.Order
+	err := r.read(ctx).Find(&orders).Error
+	return orders, err
+}
+
+func (r *GormOrderRepository) Get(ctx context.Context, id uint) (localModels.Order, error) {
+	var order localModels.Order
+	err := r.read(ctx).First(&order, id).Error
+	return order, translate(err)
+}
+
+func (r *GormOrderRepository) ListByCustomer(ctx context.Context, customerID uint) ([]localModels.Order, error) {
+	var orders []localModels.Order
+	err := r.read(ctx).Where("customer_id = ?", customerID).Find(&orders).Error
+	return orders, err
+}
+
+func (r *GormOrderRepository) Create(ctx context.Context, order *localModels.Order, lines []OrderLine) error {
+	if order.Status == "" {
+		order.Status = localModels.OrderConfirmed
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+
+		customerOrder := localModels.CustomerOrder{
+			CustomerID: order.CustomerID,
+			OrderID:    order.ID,
+		}
+		if err := tx.Create(&customerOrder).Error; err != nil {
+			return translateReference(err, ErrUnknownCustomer)
+		}
+
+		if len(lines) == 0 {
+			return nil
+		}
+		orderProducts := make([]localModels.OrderProduct, 0, len(lines))
+		for _, line := range lines {
+			orderProducts = append(orderProducts, localModels.OrderProduct{
+				OrderID:   order.ID,
+				ProductID: line.ProductID,
+				Quantity:  line.Quantity,
+			})
+		}
+		return translateReference(tx.Create(&orderProducts).Error, ErrUnknownProduct)
+	})
+	return translate(err)
+}
+
+func (r *GormOrderRepository) UpdateCustomer(ctx context.Context, id uint, customerID uint) (localModels.Order, error) {
+	db := r.db.WithContext(ctx)
+
+	var order localModels.Order
+	if err := db.First(&order, id).Error; err != nil {
+		return order, translate(err)
+	}
+
+	// The status is checked by the update itself, so a concurrent
+	// cancellation can't be overwritten
+	result := db.Model(&order).
+		Where("status IN ?", localModels.EditableStatuses).
+		Updates(localModels.Order{Order: models.Order{CustomerID: customerID}})
+	if result.Error != nil {
+		return order, translate(result.Error)
+	}
+
+	// Reload to get every column as stored
+	if err := db.First(&order, id).Error; err != nil {
+		return order, translate(err)
+	}
+	if result.RowsAffected == 0 && !slices.Contains(localModels.EditableStatuses, order.Status) {
+		return order, ErrInvalidTransition
+	}
+	return order, nil
+}
+
+func (r *GormOrderRepository) ChangeStatus(ctx context.Context, id uint, change StatusChange) (localModels.Order, error) {
+	updates := map[string]any{"status": change.To}
+	if change.To == localModels.OrderCancelled {
+		updates["cancel_reason"] = change.CancelReason
+		updates["cancel_note"] = change.CancelNote
+		updates["cancelled_at"] = time.Now().UTC()
+	}
+
+	// The status is checked by the update itself, so concurrent changes
+	// can't both apply
+	result := r.read(ctx).Model(&localModels.Order{}).
+		Where("id = ? AND status IN ?", id, change.From).
+		Updates(updates)
+	if result.Error != nil {
+		return localModels.Order{}, result.Error
+	}
+
+	var order localModels.Order
+	if err := r.read(ctx).First(&order, id).Error; err != nil {
+		return order, translate(err)
+	}
+	if result.RowsAffected == 0 {
+		return order, ErrInvalidTransition
+	}
+	return order, nil
+}
+
+func (r *GormOrderRepository) Lines(ctx context.Context, id uint) ([]OrderLine, error) {
+	lines := []OrderLine{}
+	err := r.db.WithContext(ctx).Model(&localModels.OrderProduct{}).
+		Select("product_id", "quantity").
+		Where("order_id = ?", id).
+		Order("product_id").
+		Scan(&lines).Error
+	return lines, err
+}
+
+func (r *GormOrderRepository) Delete(ctx context.Context, id uint) (localModels.Order, error) {
+	db := r.db.WithContext(ctx)
+
+	var order localModels.Order
+	if err := db.First(&order, id).Error; err != nil {
+		return order, translate(err)
+	}
+
+	err := db.Delete(&order).Error
+	return order, err
+}
+
+func (r *GormOrderRepository) ListExpiredReservations(ctx context.Context, before time.Time) ([]localModels.Order, error) {
+	var orders []localModels.Order
+	err := r.read(ctx).
+		Where("status = ? AND reservation_expires_at < ?", localModels.OrderPendingStock, before).
+		Order("id").
+		Find(&orders).Error
+	return orders, err
+}
+
+func (r *GormOrderRepository) Restore(ctx context.Context, id uint) (localModels.Order, error) {
+	db := r.db.WithContext(ctx)
+
+	result := db.Unscoped().Model(&localModels.Order{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return localModels.Order{}, result.Error
+	}
+
+	var order localModels.Order
+	if err := db.Unscoped().First(&order, id).Error; err != nil {
+		return order, translate(err)
+	}
+	if result.RowsAffected == 0 {
+		return order, ErrNotDeleted
+	}
+	return order, nil
+}
+
+func (r *GormOrderRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Unscoped().Model(&localModels.Order{}).Select("id").Where("deleted_at < ?", deletedBefore)
+
+		if err := tx.Where("order_id IN (?)", expired).Delete(&localModels.OrderProduct{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("order_id IN (?)", expired).Delete(&localModels.CustomerOrder{}).Error; err != nil {
+			return err
+		}
+		result := tx.Unscoped().Where("deleted_at < ?", deletedBefore).Delete(&localModels.Order{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+// GormCustomerRepository is a CustomerRepository backed by gorm
+type GormCustomerRepository struct {
+	db *gorm.DB
+}
+
+// NewGormCustomerRepository creates a CustomerRepository backed by db
+func NewGormCustomerRepository(db *gorm.DB) *GormCustomerRepository {
+	return &GormCustomerRepository{db: db}
+}
+
+func (r *GormCustomerRepository) Create(ctx context.Context, id uint) error {
+	customer := localModels.Customer{}
+	customer.ID = id
+	return translate(r.db.WithContext(ctx).Create(&customer).Error)
+}
+
+func (r *GormCustomerRepository) Save(ctx context.Context, id uint) error {
+	customer := localModels.Customer{}
+	customer.ID = id
+	return r.db.WithContext(ctx).Save(&customer).Error
+}
+
+func (r *GormCustomerRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&localModels.Customer{}, id).Error
+}
+
+// GormProductRepository is a ProductRepository backed by gorm
+type GormProductRepository struct {
+	db *gorm.DB
+}
+
+// NewGormProductRepository creates a ProductRepository backed by db
+func NewGormProductRepository(db *gorm.DB) *GormProductRepository {
+	return &GormProductRepository{db: db}
+}
+
+func (r *GormProductRepository) Create(ctx context.Context, id uint) error {
+	product := localModels.Product{}
+	product.ID = id
+	return translate(r.db.WithContext(ctx).Create(&product).Error)
+}
+
+func (r *GormProductRepository) Save(ctx context.Context, id uint) error {
+	product := localModels.Product{}
+	product.ID = id
+	return r.db.WithContext(ctx).Save(&product).Error
+}
+
+func (r *GormProductRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&localModels.Product{}, id).Error
+}
+
+// GormAPIKeyRepository is an APIKeyRepository backed by gorm
+type GormAPIKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewGormAPIKeyRepository creates an APIKeyRepository backed by db
+func NewGormAPIKeyRepository(db *gorm.DB) *GormAPIKeyRepository {
+	return &GormAPIKeyRepository{db: db}
+}
+
+func (r *GormAPIKeyRepository) Create(ctx context.Context, key *localModels.APIKey) error {
+	return translate(r.db.WithContext(ctx).Create(key).Error)
+}
+
+func (r *GormAPIKeyRepository) Get(ctx context.Context, id string) (localModels.APIKey, error) {
+	var key localModels.APIKey
+	err := r.db.WithContext(ctx).First(&key, "id = ?", id).Error
+	return key, translate(err)
+}
+
+func (r *GormAPIKeyRepository) List(ctx context.Context) ([]localModels.APIKey, error) {
+	var keys []localModels.APIKey
+	err := r.db.WithContext(ctx).Order("created_at, id").Find(&keys).Error
+	return keys, err
+}
+
+func (r *GormAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	result := r.db.WithContext(ctx).Model(&localModels.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Revoking twice keeps the first date
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *GormAPIKeyRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&localModels.APIKey{}).
+		Where("id = ?", id).
+		Update("last_used_at", at).Error
+}
@@ -0,0 +1,253 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/db/dbtest"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func setupMockDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	dbMock, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to create sqlmock: %v", err)
+	}
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: dbMock,
+	}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open gorm DB: %v", err)
+	}
+
+	return gormDB, mock
+}
+
+func TestGormOrderCreateRollsBack(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "orders"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "customer_orders"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "order_products"`)).
+		WillReturnError(errors.New("violates foreign key constraint"))
+	mock.ExpectRollback()
+
+	order := localModels.Order{Order: models.Order{CustomerID: 1}}
+	err := repository.NewGormOrderRepository(db).Create(context.Background(), &order, []repository.OrderLine{{ProductID: 42, Quantity: 1}})
+	if err == nil {
+		t.Fatal("expected the order creation to fail")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unfulfilled sqlmock expectations: %v", err)
+	}
+}
+
+func TestGormOrderDeleteNotFound(t *testing.T) {
+	db, mock := setupMockDB(t)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE "orders"."id" = $1`)).
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err := repository.NewGormOrderRepository(db).Delete(context.Background(), 1)
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGormSQLite(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewGorm(dbtest.New(t))
+
+	if err := repos.Customers.Create(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Customers.Create(ctx, 1); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	// Foreign keys are enforced and the whole order is rolled back
+	order := localModels.Order{Order: models.Order{CustomerID: 1}}
+	if err := repos.Orders.Create(ctx, &order, []repository.OrderLine{{ProductID: 42, Quantity: 1}}); !errors.Is(err, repository.ErrUnknownProduct) {
+		t.Fatalf("expected ErrUnknownProduct, got %v", err)
+	}
+	if err := repos.Orders.Create(ctx, &localModels.Order{Order: models.Order{CustomerID: 2}}, nil); !errors.Is(err, repository.ErrUnknownCustomer) {
+		t.Errorf("expected ErrUnknownCustomer, got %v", err)
+	}
+	orders, err := repos.Orders.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 0 {
+		t.Errorf("expected the order to be rolled back, got %v", orders)
+	}
+}
+
+func TestGormOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewGorm(dbtest.New(t))
+
+	if err := repos.Customers.Create(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint{3, 5} {
+		if err := repos.Products.Create(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	order := localModels.Order{Order: models.Order{CustomerID: 1}}
+	if err := repos.Orders.Create(ctx, &order, []repository.OrderLine{{ProductID: 5, Quantity: 1}, {ProductID: 3, Quantity: 4}}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := repos.Orders.Lines(ctx, order.ID)
+	if err != nil || len(lines) != 2 || lines[0] != (repository.OrderLine{ProductID: 3, Quantity: 4}) {
+		t.Errorf("expected the lines by product, got %v, %v", lines, err)
+	}
+	if lines, err := repos.Orders.Lines(ctx, 99); err != nil || len(lines) != 0 {
+		t.Errorf("expected an unknown order to have no lines, got %v, %v", lines, err)
+	}
+
+	cancel := repository.StatusChange{
+		From:         localModels.CancellableStatuses,
+		To:           localModels.OrderCancelled,
+		CancelReason: localModels.CancelCustomerRequest,
+		CancelNote:   "Ordered by mistake",
+	}
+	cancelled, err := repos.Orders.ChangeStatus(ctx, order.ID, cancel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != localModels.OrderCancelled || cancelled.CancelNote != "Ordered by mistake" || cancelled.CancelledAt == nil {
+		t.Errorf("expected the cancellation to be stored, got %+v", cancelled)
+	}
+	if _, err := repos.Orders.ChangeStatus(ctx, order.ID, cancel); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition, got %v", err)
+	}
+	if unchanged, err := repos.Orders.UpdateCustomer(ctx, order.ID, 2); !errors.Is(err, repository.ErrInvalidTransition) || unchanged.CustomerID != 1 {
+		t.Errorf("expected ErrInvalidTransition along with the order updating a cancelled order, got %+v, %v", unchanged, err)
+	}
+	if _, err := repos.Orders.ChangeStatus(ctx, 99, cancel); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Only orders still pending stock past their deadline expire
+	now := time.Now().UTC()
+	for _, expiresAt := range []time.Time{now.Add(-time.Minute), now.Add(time.Minute)} {
+		pending := localModels.Order{Order: models.Order{CustomerID: 1}, Status: localModels.OrderPendingStock, ReservationExpiresAt: &expiresAt}
+		if err := repos.Orders.Create(ctx, &pending, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expired, err := repos.Orders.ListExpiredReservations(ctx, now)
+	if err != nil || len(expired) != 1 || expired[0].ID != 2 {
+		t.Errorf("expected order 2 to be expired, got %v, %v", expired, err)
+	}
+
+	// Deleted orders expire too when asked for, and can be cancelled
+	if _, err := repos.Orders.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if expired, err := repos.Orders.ListExpiredReservations(ctx, now); err != nil || len(expired) != 0 {
+		t.Errorf("expected deleted orders to be hidden, got %v, %v", expired, err)
+	}
+	withDeleted := repository.IncludeDeleted(ctx)
+	if expired, err := repos.Orders.ListExpiredReservations(withDeleted, now); err != nil || len(expired) != 1 || expired[0].ID != 2 {
+		t.Errorf("expected deleted order 2 to be expired, got %v, %v", expired, err)
+	}
+	if _, err := repos.Orders.ChangeStatus(ctx, 2, cancel); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted order, got %v", err)
+	}
+	cancel.From = []localModels.OrderStatus{localModels.OrderPendingStock}
+	if cancelled, err := repos.Orders.ChangeStatus(withDeleted, 2, cancel); err != nil || cancelled.Status != localModels.OrderCancelled {
+		t.Errorf("expected deleted order 2 to be cancelled, got %+v, %v", cancelled, err)
+	}
+}
+
+func TestGormPurge(t *testing.T) {
+	ctx := context.Background()
+	db := dbtest.New(t)
+	repos := repository.NewGorm(db)
+
+	if err := repos.Customers.Create(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repos.Products.Create(ctx, 3); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		order := localModels.Order{Order: models.Order{CustomerID: 1}}
+		if err := repos.Orders.Create(ctx, &order, []repository.OrderLine{{ProductID: 3, Quantity: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repos.Orders.Delete(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := repos.Orders.Purge(ctx, time.Now().Add(time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("expected the deleted order to be purged, got %d, %v", purged, err)
+	}
+	for _, model := range []any{&localModels.Order{}, &localModels.OrderProduct{}, &localModels.CustomerOrder{}} {
+		var count int64
+		if err := db.Unscoped().Model(model).Count(&count).Error; err != nil || count != 1 {
+			t.Errorf("expected only the rows of order 2 to be left in %T, got %d, %v", model, count, err)
+		}
+	}
+	if _, err := repos.Orders.Restore(ctx, 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound restoring a purged order, got %v", err)
+	}
+}
+
+func TestGormAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	keys := repository.NewGormAPIKeyRepository(dbtest.New(t))
+
+	key := localModels.APIKey{ID: "abc", Name: "billing", Hash: "hash", Scopes: "orders:read"}
+	if err := keys.Create(ctx, &key); err != nil {
+		t.Fatal(err)
+	}
+
+	used := time.Now().UTC().Truncate(time.Second)
+	if err := keys.Touch(ctx, "abc", used); err != nil {
+		t.Fatal(err)
+	}
+	revoked := used.Add(time.Hour)
+	if err := keys.Revoke(ctx, "abc", revoked); err != nil {
+		t.Fatal(err)
+	}
+	// Revoking again keeps the first date
+	if err := keys.Revoke(ctx, "abc", revoked.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := keys.Get(ctx, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LastUsedAt == nil || !stored.LastUsedAt.Equal(used) || stored.RevokedAt == nil || !stored.RevokedAt.Equal(revoked) {
+		t.Errorf("expected last used %v and revoked %v, got %+v", used, revoked, stored)
+	}
+
+	if err := keys.Revoke(ctx, "missing", revoked); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	list, err := keys.List(ctx)
+	if err != nil || len(list) != 1 {
+		t.Errorf("expected one key, got %v %v", list, err)
+	}
+}
@@ -0,0 +1,386 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"gorm.io/gorm"
+)
+
+// NewMemory creates empty in-memory repositories, for tests and running
+// without a database. They don't enforce foreign keys.
+func NewMemory() Repositories {
+	return Repositories{
+		Orders:    NewMemoryOrderRepository(),
+		Customers: NewMemoryCustomerRepository(),
+		Products:  NewMemoryProductRepository(),
+		APIKeys:   NewMemoryAPIKeyRepository(),
+	}
+}
+
+// MemoryOrderRepository is an OrderRepository kept in memory. Deleted
+// orders are kept but hidden, like soft deletes in the database.
+type MemoryOrderRepository struct {
+	mu     sync.Mutex
+	nextID uint
+	orders map[uint]localModels.Order
+	lines  map[uint][]OrderLine
+}
+
+// NewMemoryOrderRepository creates an empty MemoryOrderRepository
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{
+		nextID: 1,
+		orders: map[uint]localModels.Order{},
+		lines:  map[uint][]OrderLine{},
+	}
+}
+
+// find returns the orders visible to ctx that match keep, by ID
+func (r *MemoryOrderRepository) find(ctx context.Context, keep func(localModels.Order) bool) []localModels.Order {
+	orders := []localModels.Order{}
+	for _, id := range slices.Sorted(maps.Keys(r.orders)) {
+		order := r.orders[id]
+		if (!order.DeletedAt.Valid || includesDeleted(ctx)) && keep(order) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+func (r *MemoryOrderRepository) List(ctx context.Context) ([]localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.find(ctx, func(localModels.Order) bool { return true }), nil
+}
+
+func (r *MemoryOrderRepository) Get(ctx context.Context, id uint) (localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok || (order.DeletedAt.Valid && !includesDeleted(ctx)) {
+		return localModels.Order{}, ErrNotFound
+	}
+	return order, nil
+}
+
+func (r *MemoryOrderRepository) ListByCustomer(ctx context.Context, customerID uint) ([]localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.find(ctx, func(order localModels.Order) bool { return order.CustomerID == customerID }), nil
+}
+
+func (r *MemoryOrderRepository) Create(ctx context.Context, order *localModels.Order, lines []OrderLine) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if order.ID == 0 {
+		order.ID = r.nextID
+	} else if _, ok := r.orders[order.ID]; ok {
+		return ErrDuplicate
+	}
+	r.nextID = max(r.nextID, order.ID+1)
+
+	now := time.Now()
+	order.CreatedAt, order.UpdatedAt = now, now
+	if order.Status == "" {
+		order.Status = localModels.OrderConfirmed
+	}
+
+	stored := *order
+	stored.Products = nil
+	r.orders[order.ID] = stored
+	r.lines[order.ID] = slices.Clone(lines)
+	return nil
+}
+
+func (r *MemoryOrderRepository) UpdateCustomer(ctx context.Context, id uint, customerID uint) (localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok || order.DeletedAt.Valid {
+		return localModels.Order{}, ErrNotFound
+	}
+	if !slices.Contains(localModels.EditableStatuses, order.Status) {
+		return order, ErrInvalidTransition
+	}
+
+	// Like gorm's Updates, zero values are left unchanged
+	if customerID != 0 {
+		order.CustomerID = customerID
+	}
+	order.UpdatedAt = time.Now()
+	r.orders[id] = order
+	return order, nil
+}
+
+func (r *MemoryOrderRepository) Delete(ctx context.Context, id uint) (localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok || order.DeletedAt.Valid {
+		return localModels.Order{}, ErrNotFound
+	}
+
+	deleted := order
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.orders[id] = deleted
+	return order, nil
+}
+
+func (r *MemoryOrderRepository) ChangeStatus(ctx context.Context, id uint, change StatusChange) (localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok || (order.DeletedAt.Valid && !includesDeleted(ctx)) {
+		return localModels.Order{}, ErrNotFound
+	}
+	if !slices.Contains(change.From, order.Status) {
+		return order, ErrInvalidTransition
+	}
+
+	now := time.Now()
+	order.Status = change.To
+	if change.To == localModels.OrderCancelled {
+		cancelledAt := now.UTC()
+		order.CancelReason, order.CancelNote, order.CancelledAt = change.CancelReason, change.CancelNote, &cancelledAt
+	}
+	order.UpdatedAt = now
+	r.orders[id] = order
+	return order, nil
+}
+
+func (r *MemoryOrderRepository) ListExpiredReservations(ctx context.Context, before time.Time) ([]localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.find(ctx, func(order localModels.Order) bool {
+		return order.Status == localModels.OrderPendingStock && order.ReservationExpiresAt != nil && order.ReservationExpiresAt.Before(before)
+	}), nil
+}
+
+func (r *MemoryOrderRepository) Restore(ctx context.Context, id uint) (localModels.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order, ok := r.orders[id]
+	if !ok {
+		return localModels.Order{}, ErrNotFound
+	}
+	if !order.DeletedAt.Valid {
+		return order, ErrNotDeleted
+	}
+
+	order.DeletedAt = gorm.DeletedAt{}
+	order.UpdatedAt = time.Now()
+	r.orders[id] = order
+	return order, nil
+}
+
+func (r *MemoryOrderRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, order := range r.orders {
+		if order.DeletedAt.Valid && order.DeletedAt.Time.Before(deletedBefore) {
+			delete(r.orders, id)
+			delete(r.lines, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *MemoryOrderRepository) Lines(ctx context.Context, id uint) ([]OrderLine, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.SortedFunc(slices.Values(r.lines[id]), func(a, b OrderLine) int {
+		return cmp.Compare(a.ProductID, b.ProductID)
+	}), nil
+}
+
+// memorySet keeps the IDs of the customers or products known from events,
+// mapped to whether they are deleted
+type memorySet struct {
+	mu  sync.Mutex
+	ids map[uint]bool
+}
+
+func (s *memorySet) create(id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; ok {
+		return ErrDuplicate
+	}
+	s.ids[id] = false
+	return nil
+}
+
+func (s *memorySet) save(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ids[id] = false
+}
+
+func (s *memorySet) delete(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.ids[id]; ok {
+		s.ids[id] = true
+	}
+}
+
+func (s *memorySet) exists(id uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted, ok := s.ids[id]
+	return ok && !deleted
+}
+
+// MemoryCustomerRepository is a CustomerRepository kept in memory
+type MemoryCustomerRepository struct {
+	set memorySet
+}
+
+// NewMemoryCustomerRepository creates an empty MemoryCustomerRepository
+func NewMemoryCustomerRepository() *MemoryCustomerRepository {
+	return &MemoryCustomerRepository{set: memorySet{ids: map[uint]bool{}}}
+}
+
+func (r *MemoryCustomerRepository) Create(ctx context.Context, id uint) error {
+	return r.set.create(id)
+}
+
+func (r *MemoryCustomerRepository) Save(ctx context.Context, id uint) error {
+	r.set.save(id)
+	return nil
+}
+
+func (r *MemoryCustomerRepository) Delete(ctx context.Context, id uint) error {
+	r.set.delete(id)
+	return nil
+}
+
+// Exists tells whether the customer is known and not deleted
+func (r *MemoryCustomerRepository) Exists(id uint) bool {
+	return r.set.exists(id)
+}
+
+// MemoryProductRepository is a ProductRepository kept in memory
+type MemoryProductRepository struct {
+	set memorySet
+}
+
+// NewMemoryProductRepository creates an empty MemoryProductRepository
+func NewMemoryProductRepository() *MemoryProductRepository {
+	return &MemoryProductRepository{set: memorySet{ids: map[uint]bool{}}}
+}
+
+func (r *MemoryProductRepository) Create(ctx context.Context, id uint) error {
+	return r.set.create(id)
+}
+
+func (r *MemoryProductRepository) Save(ctx context.Context, id uint) error {
+	r.set.save(id)
+	return nil
+}
+
+func (r *MemoryProductRepository) Delete(ctx context.Context, id uint) error {
+	r.set.delete(id)
+	return nil
+}
+
+// Exists tells whether the product is known and not deleted
+func (r *MemoryProductRepository) Exists(id uint) bool {
+	return r.set.exists(id)
+}
+
+// MemoryAPIKeyRepository is an APIKeyRepository kept in memory
+type MemoryAPIKeyRepository struct {
+	mu   sync.Mutex
+	keys map[string]localModels.APIKey
+}
+
+// NewMemoryAPIKeyRepository creates an empty MemoryAPIKeyRepository
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{keys: map[string]localModels.APIKey{}}
+}
+
+func (r *MemoryAPIKeyRepository) Create(ctx context.Context, key *localModels.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[key.ID]; ok {
+		return ErrDuplicate
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	r.keys[key.ID] = *key
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) Get(ctx context.Context, id string) (localModels.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return localModels.APIKey{}, ErrNotFound
+	}
+	return key, nil
+}
+
+func (r *MemoryAPIKeyRepository) List(ctx context.Context) ([]localModels.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := slices.Collect(maps.Values(r.keys))
+	slices.SortFunc(keys, func(a, b localModels.APIKey) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return keys, nil
+}
+
+func (r *MemoryAPIKeyRepository) Revoke(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if key.RevokedAt == nil {
+		key.RevokedAt = &at
+		r.keys[id] = key
+	}
+	return nil
+}
+
+func (r *MemoryAPIKeyRepository) Touch(ctx context.Context, id string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, ok := r.keys[id]; ok {
+		key.LastUsedAt = &at
+		r.keys[id] = key
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+)
+
+// The in-memory repositories must stay interchangeable with the gorm ones
+var (
+	_ repository.OrderRepository    = (*repository.MemoryOrderRepository)(nil)
+	_ repository.OrderRepository    = (*repository.GormOrderRepository)(nil)
+	_ repository.CustomerRepository = (*repository.MemoryCustomerRepository)(nil)
+	_ repository.CustomerRepository = (*repository.GormCustomerRepository)(nil)
+	_ repository.ProductRepository  = (*repository.MemoryProductRepository)(nil)
+	_ repository.ProductRepository  = (*repository.GormProductRepository)(nil)
+)
+
+func TestMemoryOrderRepository(t *testing.T) {
+	ctx := context.Background()
+	orders := repository.NewMemoryOrderRepository()
+
+	first := localModels.Order{Order: models.Order{CustomerID: 1}}
+	if err := orders.Create(ctx, &first, []repository.OrderLine{{ProductID: 5, Quantity: 1}, {ProductID: 3, Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	second := localModels.Order{Order: models.Order{CustomerID: 2}}
+	if err := orders.Create(ctx, &second, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if first.ID != 1 || second.ID != 2 || first.CreatedAt.IsZero() {
+		t.Errorf("expected IDs and timestamps to be assigned, got %+v and %+v", first, second)
+	}
+	if lines, _ := orders.Lines(ctx, first.ID); !slices.Equal(lines, []repository.OrderLine{{ProductID: 3, Quantity: 2}, {ProductID: 5, Quantity: 1}}) {
+		t.Errorf("expected lines of products 3 and 5, got %v", lines)
+	}
+	if lines, err := orders.Lines(ctx, 99); err != nil || len(lines) != 0 {
+		t.Errorf("expected an unknown order to have no lines, got %v, %v", lines, err)
+	}
+	if first.Status != localModels.OrderConfirmed {
+		t.Errorf("expected new orders to be confirmed, got %q", first.Status)
+	}
+
+	updated, err := orders.UpdateCustomer(ctx, second.ID, 3)
+	if err != nil || updated.CustomerID != 3 {
+		t.Errorf("expected customer to be updated, got %+v, %v", updated, err)
+	}
+
+	cancel := repository.StatusChange{
+		From:         localModels.CancellableStatuses,
+		To:           localModels.OrderCancelled,
+		CancelReason: localModels.CancelDuplicate,
+	}
+	cancelled, err := orders.ChangeStatus(ctx, second.ID, cancel)
+	if err != nil || cancelled.Status != localModels.OrderCancelled || cancelled.CancelReason != localModels.CancelDuplicate || cancelled.CancelledAt == nil {
+		t.Errorf("expected order to be cancelled, got %+v, %v", cancelled, err)
+	}
+	if again, err := orders.ChangeStatus(ctx, second.ID, cancel); !errors.Is(err, repository.ErrInvalidTransition) || again.Status != localModels.OrderCancelled {
+		t.Errorf("expected ErrInvalidTransition along with the order cancelling twice, got %+v, %v", again, err)
+	}
+
+	if _, err := orders.UpdateCustomer(ctx, second.ID, 4); !errors.Is(err, repository.ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition updating a cancelled order, got %v", err)
+	}
+
+	if _, err := orders.Delete(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orders.Get(ctx, first.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected deleted order to be hidden, got %v", err)
+	}
+	if _, err := orders.Delete(ctx, first.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+
+	all, err := orders.List(ctx)
+	if err != nil || len(all) != 1 || all[0].ID != second.ID {
+		t.Errorf("expected only order 2 to be listed, got %v, %v", all, err)
+	}
+	byCustomer, err := orders.ListByCustomer(ctx, 3)
+	if err != nil || len(byCustomer) != 1 {
+		t.Errorf("expected one order for customer 3, got %v, %v", byCustomer, err)
+	}
+
+	if all, _ := orders.List(repository.IncludeDeleted(ctx)); len(all) != 2 {
+		t.Errorf("expected the deleted order to be listed on demand, got %v", all)
+	}
+	if _, err := orders.Restore(ctx, second.ID); !errors.Is(err, repository.ErrNotDeleted) {
+		t.Errorf("expected ErrNotDeleted restoring an order that isn't deleted, got %v", err)
+	}
+	if restored, err := orders.Restore(ctx, first.ID); err != nil || restored.DeletedAt.Valid {
+		t.Errorf("expected order 1 to be restored, got %+v, %v", restored, err)
+	}
+	if _, err := orders.Delete(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := orders.Purge(ctx, time.Now().Add(time.Hour)); err != nil || purged != 1 {
+		t.Errorf("expected one order to be purged, got %d, %v", purged, err)
+	}
+	if _, err := orders.Get(repository.IncludeDeleted(ctx), first.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected the purged order to be gone, got %v", err)
+	}
+}
+
+func TestMemoryCustomerRepository(t *testing.T) {
+	ctx := context.Background()
+	customers := repository.NewMemoryCustomerRepository()
+
+	if err := customers.Create(ctx, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := customers.Create(ctx, 4); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate, got %v", err)
+	}
+
+	if err := customers.Delete(ctx, 4); err != nil || customers.Exists(4) {
+		t.Errorf("expected customer to be deleted, got %v", err)
+	}
+
+	// Save restores a deleted customer
+	if err := customers.Save(ctx, 4); err != nil || !customers.Exists(4) {
+		t.Errorf("expected customer to be restored, got %v", err)
+	}
+}
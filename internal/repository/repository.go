@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record doesn't exist
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when creating a record whose ID is taken
+	ErrDuplicate = errors.New("record already exists")
+	// ErrInvalidReference is returned when a record refers to another that
+	// doesn't exist
+	ErrInvalidReference = errors.New("referenced record not found")
+	// ErrUnknownCustomer is returned when creating an order for an unknown
+	// customer
+	ErrUnknownCustomer = fmt.Errorf("%w: unknown customer", ErrInvalidReference)
+	// ErrUnknownProduct is returned when creating an order with an unknown
+	// product
+	ErrUnknownProduct = fmt.Errorf("%w: unknown product", ErrInvalidReference)
+	// ErrInvalidTransition is returned when the status of an order doesn't
+	// allow the requested change
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrNotDeleted is returned when restoring an order that isn't deleted
+	ErrNotDeleted = errors.New("record not deleted")
+)
+
+type contextKey string
+
+const includeDeletedKey contextKey = "repository/include-deleted"
+
+// IncludeDeleted returns a copy of ctx whose reads and status changes of
+// orders include the deleted ones
+func IncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey, true)
+}
+
+// includesDeleted tells whether the reads of ctx include deleted orders
+func includesDeleted(ctx context.Context) bool {
+	include, _ := ctx.Value(includeDeletedKey).(bool)
+	return include
+}
+
+// OrderLine is a product of an order and the number of units ordered
+type OrderLine struct {
+	ProductID uint
+	Quantity  int
+}
+
+// StatusChange moves an order from one of the From statuses to To. The
+// reason and note are recorded when cancelling.
+type StatusChange struct {
+	From         []localModels.OrderStatus
+	To           localModels.OrderStatus
+	CancelReason localModels.CancelReason
+	CancelNote   string
+}
+
+// OrderRepository stores orders along with their customer and product lines.
+// Deleted orders are hidden from reads, unless their context comes from
+// IncludeDeleted.
+type OrderRepository interface {
+	// List returns every order
+	List(ctx context.Context) ([]localModels.Order, error)
+	// Get returns the order with the given ID, or ErrNotFound
+	Get(ctx context.Context, id uint) (localModels.Order, error)
+	// ListByCustomer returns the orders of a customer
+	ListByCustomer(ctx context.Context, customerID uint) ([]localModels.Order, error)
+	// Create stores order, which gets its ID, and its lines atomically.
+	// Orders without a status are confirmed. It returns ErrUnknownCustomer
+	// or ErrUnknownProduct when they don't exist.
+	Create(ctx context.Context, order *localModels.Order, lines []OrderLine) error
+	// UpdateCustomer changes the customer of an order and returns the
+	// updated order. It returns ErrNotFound, or ErrInvalidTransition along
+	// with the order as is when its status isn't one of EditableStatuses.
+	UpdateCustomer(ctx context.Context, id uint, customerID uint) (localModels.Order, error)
+	// ChangeStatus applies change to an order atomically and returns the
+	// updated order. It returns ErrNotFound, or ErrInvalidTransition along
+	// with the order as is when its status isn't one of change.From.
+	ChangeStatus(ctx context.Context, id uint, change StatusChange) (localModels.Order, error)
+	// Lines returns the product lines of an order, by product. An unknown
+	// order has no lines, it isn't an error.
+	Lines(ctx context.Context, id uint) ([]OrderLine, error)
+	// ListExpiredReservations returns the orders still pending stock whose
+	// reservation expired before the given time
+	ListExpiredReservations(ctx context.Context, before time.Time) ([]localModels.Order, error)
+	// Delete soft deletes an order and returns it, or ErrNotFound
+	Delete(ctx context.Context, id uint) (localModels.Order, error)
+	// Restore undoes the deletion of an order and returns it. It returns
+	// ErrNotFound, or ErrNotDeleted when the order isn't deleted.
+	Restore(ctx context.Context, id uint) (localModels.Order, error)
+	// Purge permanently removes the orders deleted before the given time
+	// along with their customer and product lines, and returns how many
+	// orders were removed
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// CustomerRepository stores the customers known from customer events
+type CustomerRepository interface {
+	Create(ctx context.Context, id uint) error
+	// Save creates or restores the customer
+	Save(ctx context.Context, id uint) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// ProductRepository stores the products known from product events
+type ProductRepository interface {
+	Create(ctx context.Context, id uint) error
+	// Save creates or restores the product
+	Save(ctx context.Context, id uint) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// APIKeyRepository stores the API keys of the services calling the API
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *localModels.APIKey) error
+	// Get returns the key with the given ID, or ErrNotFound
+	Get(ctx context.Context, id string) (localModels.APIKey, error)
+	// List returns every key, revoked ones included, by creation date
+	List(ctx context.Context) ([]localModels.APIKey, error)
+	// Revoke marks a key as revoked at the given time, or returns ErrNotFound
+	Revoke(ctx context.Context, id string, at time.Time) error
+	// Touch records that a key was used at the given time
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// Repositories groups the repositories of the service
+type Repositories struct {
+	Orders    OrderRepository
+	Customers CustomerRepository
+	Products  ProductRepository
+	APIKeys   APIKeyRepository
+}
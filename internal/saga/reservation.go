@@ -0,0 +1,192 @@
+// Package saga coordinates the placement of orders with the other services
+// over events
+package saga
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/events"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/logging"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/go-chi/metrics"
+)
+
+// sweepInterval is how often expired reservations are looked for
+const sweepInterval = 15 * time.Second
+
+var reservationsCounter = metrics.CounterWith[reservationLabels]("stock_reservations_total", "Total number of stock reservations settled, by outcome.")
+
+// reservationLabels defines labels for the stock reservations counter
+type reservationLabels struct {
+	Outcome string `label:"outcome"`
+}
+
+// Reservations is the stock reservation saga. A new order waits in
+// pending_stock while its order.created event asks the Products service to
+// reserve the stock. The order is confirmed on product.stock.reserved, and
+// cancelled on product.stock.rejected or once its reservation expires.
+//
+// The state of the saga is the status of the order along with the deadline
+// of its reservation, so each step is a single conditional update and
+// redelivered events are harmless. Cancelled orders are published as
+// order.cancelled, which compensates a reservation made too late.
+type Reservations struct {
+	orders    repository.OrderRepository
+	publisher rabbitmq.EventPublisher
+	logger    *slog.Logger
+}
+
+// NewReservations creates the saga moving orders on
+func NewReservations(orders repository.OrderRepository, publisher rabbitmq.EventPublisher, logger *slog.Logger) *Reservations {
+	return &Reservations{orders: orders, publisher: publisher, logger: logger}
+}
+
+// Reserved confirms an order whose stock was reserved. An order deleted
+// meanwhile is cancelled instead, and when the order is cancelled,
+// order.cancelled is published so the stock is released.
+func (r *Reservations) Reserved(ctx context.Context, orderID uint) error {
+	// Deleted orders hold stock as well
+	ctx = repository.IncludeDeleted(ctx)
+	order, err := r.orders.Get(ctx, orderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		r.logger.WarnContext(ctx, "Stock reserved for an unknown order, ignoring it")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if order.DeletedAt.Valid {
+		order, err = r.cancel(ctx, orderID, localModels.CancelOther, "Order deleted before its stock was reserved")
+	} else {
+		order, err = r.orders.ChangeStatus(ctx, orderID, repository.StatusChange{
+			From: []localModels.OrderStatus{localModels.OrderPendingStock},
+			To:   localModels.OrderConfirmed,
+		})
+	}
+	switch {
+	case errors.Is(err, repository.ErrInvalidTransition) && order.Status != localModels.OrderCancelled:
+		r.logger.InfoContext(ctx, "Order already settled, ignoring the reservation", "status", order.Status)
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		// Purged since read
+		r.logger.WarnContext(ctx, "Stock reserved for an unknown order, ignoring it")
+		return nil
+	case err != nil && !errors.Is(err, repository.ErrInvalidTransition):
+		return err
+	}
+
+	if order.Status == localModels.OrderCancelled {
+		r.logger.WarnContext(ctx, "Stock reserved for a cancelled order, releasing it", "reason", order.CancelReason)
+		reservationsCounter.Inc(reservationLabels{Outcome: "released"})
+		// Failing lets the event be redelivered, until the release is out
+		return r.publish(ctx, rabbitmq.OrderCancelled, order)
+	}
+
+	reservationsCounter.Inc(reservationLabels{Outcome: "reserved"})
+	r.logger.InfoContext(ctx, "Order confirmed")
+	if err := r.publish(ctx, rabbitmq.OrderConfirmed, order); err != nil {
+		// Log the error but don't fail, the order was already confirmed
+		r.logger.WarnContext(ctx, "Failed to publish order event", "event", rabbitmq.OrderConfirmed, "error", err)
+	}
+	return nil
+}
+
+// Rejected cancels an order whose stock couldn't be reserved, deleted or not
+func (r *Reservations) Rejected(ctx context.Context, orderID uint, reason string) error {
+	ctx = repository.IncludeDeleted(ctx)
+	order, err := r.cancel(ctx, orderID, localModels.CancelOutOfStock, reason)
+	switch {
+	case errors.Is(err, repository.ErrInvalidTransition):
+		r.logger.InfoContext(ctx, "Order already settled, ignoring the rejection", "status", order.Status)
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		r.logger.WarnContext(ctx, "Stock rejected for an unknown order, ignoring it")
+		return nil
+	case err != nil:
+		return err
+	}
+
+	reservationsCounter.Inc(reservationLabels{Outcome: "rejected"})
+	r.logger.InfoContext(ctx, "Order cancelled, its stock was rejected")
+	if err := r.publish(ctx, rabbitmq.OrderCancelled, order); err != nil {
+		// Log the error but don't fail, the order was already cancelled
+		r.logger.WarnContext(ctx, "Failed to publish order event", "event", rabbitmq.OrderCancelled, "error", err)
+	}
+	return nil
+}
+
+// Expire cancels the orders whose reservation expired before now and
+// returns how many were cancelled. Their order.cancelled event releases the
+// stock the Products service may have reserved anyway.
+func (r *Reservations) Expire(ctx context.Context, now time.Time) (int, error) {
+	// Deleted orders expire as well, releasing their stock
+	ctx = repository.IncludeDeleted(ctx)
+	expired, err := r.orders.ListExpiredReservations(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+	for _, order := range expired {
+		ctx := logging.With(ctx, "order_id", order.ID)
+
+		order, err := r.cancel(ctx, order.ID, localModels.CancelReservationExpired, "")
+		if errors.Is(err, repository.ErrInvalidTransition) || errors.Is(err, repository.ErrNotFound) {
+			// Settled or deleted since listed
+			continue
+		}
+		if err != nil {
+			return cancelled, err
+		}
+		cancelled++
+
+		reservationsCounter.Inc(reservationLabels{Outcome: "expired"})
+		r.logger.WarnContext(ctx, "Order cancelled, its stock wasn't reserved in time")
+		if err := r.publish(ctx, rabbitmq.OrderCancelled, order); err != nil {
+			r.logger.WarnContext(ctx, "Failed to publish order event", "event", rabbitmq.OrderCancelled, "error", err)
+		}
+	}
+	return cancelled, nil
+}
+
+// Run expires reservations periodically until ctx is done
+func (r *Reservations) Run(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Expire(ctx, time.Now().UTC()); err != nil && ctx.Err() == nil {
+				r.logger.ErrorContext(ctx, "Failed to expire stock reservations", "error", err)
+			}
+		}
+	}
+}
+
+// cancel cancels an order pending stock
+func (r *Reservations) cancel(ctx context.Context, orderID uint, reason localModels.CancelReason, note string) (localModels.Order, error) {
+	return r.orders.ChangeStatus(ctx, orderID, repository.StatusChange{
+		From:         []localModels.OrderStatus{localModels.OrderPendingStock},
+		To:           localModels.OrderCancelled,
+		CancelReason: reason,
+		CancelNote:   note,
+	})
+}
+
+// publish publishes an event about order along with its lines
+func (r *Reservations) publish(ctx context.Context, eventType events.EventType, order localModels.Order) error {
+	lines, err := r.orders.Lines(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	return r.publisher.PublishOrderEvent(ctx, eventType, rabbitmq.NewEventOrder(order, lines))
+}
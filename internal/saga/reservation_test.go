@@ -0,0 +1,146 @@
+package saga_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/PayeTonKawa-EPSI-2025/Common-V2/models"
+	localModels "github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/models"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/rabbitmq"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/repository"
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/saga"
+)
+
+// createPending creates an order pending stock whose reservation expires at
+// expiresAt, with 2 units of product 7
+func createPending(t *testing.T, orders repository.OrderRepository, expiresAt time.Time) uint {
+	t.Helper()
+	order := localModels.Order{
+		Order:                models.Order{CustomerID: 1},
+		Status:               localModels.OrderPendingStock,
+		ReservationExpiresAt: &expiresAt,
+	}
+	if err := orders.Create(context.Background(), &order, []repository.OrderLine{{ProductID: 7, Quantity: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	return order.ID
+}
+
+func TestReservations(t *testing.T) {
+	ctx := context.Background()
+	orders := repository.NewMemoryOrderRepository()
+	publisher := &rabbitmq.RecordingPublisher{}
+	reservations := saga.NewReservations(orders, publisher, slog.New(slog.DiscardHandler))
+
+	now := time.Now().UTC()
+	reserved := createPending(t, orders, now.Add(time.Minute))
+	rejected := createPending(t, orders, now.Add(time.Minute))
+	expired := createPending(t, orders, now.Add(-time.Minute))
+
+	if err := reservations.Reserved(ctx, reserved); err != nil {
+		t.Fatal(err)
+	}
+	if err := reservations.Rejected(ctx, rejected, "Product 7 is out of stock"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reservations.Expire(ctx, now); err != nil || n != 1 {
+		t.Errorf("expected one reservation to expire, got %d, %v", n, err)
+	}
+
+	for id, expected := range map[uint]localModels.CancelReason{
+		reserved: "",
+		rejected: localModels.CancelOutOfStock,
+		expired:  localModels.CancelReservationExpired,
+	} {
+		order, err := orders.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.CancelReason != expected || (expected == "") != (order.Status == localModels.OrderConfirmed) {
+			t.Errorf("expected order %d to be settled with %q, got %+v", id, expected, order)
+		}
+	}
+
+	recorded := publisher.Events()
+	if len(recorded) != 3 || recorded[0].Type != rabbitmq.OrderConfirmed || recorded[1].Type != rabbitmq.OrderCancelled || recorded[2].Type != rabbitmq.OrderCancelled {
+		t.Fatalf("expected order.confirmed then order.cancelled twice, got %+v", recorded)
+	}
+	if lines := recorded[2].Order.Lines; len(lines) != 1 || lines[0].Quantity != 2 {
+		t.Errorf("expected the lines to be released, got %+v", lines)
+	}
+	if c := recorded[1].Order.Cancellation; c == nil || c.Reason != "out_of_stock" || c.Note != "Product 7 is out of stock" {
+		t.Errorf("expected the rejection to be published, got %+v", c)
+	}
+
+	// Redelivered answers change nothing
+	if err := reservations.Reserved(ctx, reserved); err != nil {
+		t.Errorf("expected a redelivered reservation to be ignored, got %v", err)
+	}
+	if err := reservations.Rejected(ctx, rejected, ""); err != nil {
+		t.Errorf("expected a redelivered rejection to be ignored, got %v", err)
+	}
+	if err := reservations.Reserved(ctx, 99); err != nil {
+		t.Errorf("expected a reservation for an unknown order to be ignored, got %v", err)
+	}
+	if n := len(publisher.Events()); n != 3 {
+		t.Errorf("expected no more events, got %d", n)
+	}
+
+	// Stock reserved too late is released
+	if err := reservations.Reserved(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+	recorded = publisher.Events()
+	if len(recorded) != 4 || recorded[3].Type != rabbitmq.OrderCancelled || recorded[3].Order.OrderID != expired {
+		t.Errorf("expected order.cancelled to be published again, got %+v", recorded)
+	}
+	publisher.Err = errors.New("broker down")
+	if err := reservations.Reserved(ctx, expired); err == nil {
+		t.Error("expected a failed release to be reported, for the event to be redelivered")
+	}
+}
+
+func TestReservationsOfDeletedOrders(t *testing.T) {
+	ctx := context.Background()
+	orders := repository.NewMemoryOrderRepository()
+	publisher := &rabbitmq.RecordingPublisher{}
+	reservations := saga.NewReservations(orders, publisher, slog.New(slog.DiscardHandler))
+
+	now := time.Now().UTC()
+	reserved := createPending(t, orders, now.Add(time.Minute))
+	expired := createPending(t, orders, now.Add(-time.Minute))
+	for _, id := range []uint{reserved, expired} {
+		if _, err := orders.Delete(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Both are cancelled, releasing their stock
+	if err := reservations.Reserved(ctx, reserved); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reservations.Expire(ctx, now); err != nil || n != 1 {
+		t.Errorf("expected the deleted order to expire, got %d, %v", n, err)
+	}
+
+	for id, expected := range map[uint]localModels.CancelReason{
+		reserved: localModels.CancelOther,
+		expired:  localModels.CancelReservationExpired,
+	} {
+		order, err := orders.Get(repository.IncludeDeleted(ctx), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if order.Status != localModels.OrderCancelled || order.CancelReason != expected {
+			t.Errorf("expected order %d to be cancelled with %q, got %+v", id, expected, order)
+		}
+	}
+	recorded := publisher.Events()
+	if len(recorded) != 2 || recorded[0].Type != rabbitmq.OrderCancelled || recorded[1].Type != rabbitmq.OrderCancelled ||
+		len(recorded[0].Order.Lines) != 1 || len(recorded[1].Order.Lines) != 1 {
+		t.Errorf("expected order.cancelled with the lines of both orders, got %+v", recorded)
+	}
+}
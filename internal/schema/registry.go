@@ -0,0 +1,178 @@
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// idPrefix is the prefix of every schema $id, followed by family:version
+const idPrefix = "urn:paye-ton-kawa:schemas:"
+
+//go:embed schemas
+var files embed.FS
+
+// eventFamilies maps incoming event types to the schema family describing them
+var eventFamilies = map[string]string{
+	"customer.created": "customer-event",
+	"customer.updated": "customer-event",
+	"customer.deleted": "customer-event",
+	"product.created":  "product-event",
+	"product.updated":  "product-event",
+	"product.deleted":  "product-event",
+
+	"product.stock.reserved": "stock-event",
+	"product.stock.rejected": "stock-event",
+}
+
+// ErrNoSchema is returned when no schema is registered for an event type
+var ErrNoSchema = errors.New("no schema registered for event type")
+
+// ValidationError lists why a payload does not match its schema
+type ValidationError struct {
+	EventType string
+	Version   string
+	Errors    []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s event (schema %s): %s", e.EventType, e.Version, strings.Join(e.Errors, "; "))
+}
+
+// Registry holds the compiled, versioned schemas embedded in the binary
+type Registry struct {
+	schemas map[string]map[string]*jsonschema.Schema // family -> version -> schema
+	latest  map[string]string                        // family -> latest version
+}
+
+// NewRegistry compiles every schema under schemas/<family>/<version>.json
+func NewRegistry() (*Registry, error) {
+	r := &Registry{
+		schemas: make(map[string]map[string]*jsonschema.Schema),
+		latest:  make(map[string]string),
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+
+	err := fs.WalkDir(files, "schemas", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".json" {
+			return err
+		}
+
+		family := path.Base(path.Dir(p))
+		version := strings.TrimSuffix(path.Base(p), ".json")
+
+		raw, err := files.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", p, err)
+		}
+
+		id := SchemaID(family, version)
+		if err := compiler.AddResource(id, doc); err != nil {
+			return fmt.Errorf("schema %s: %w", p, err)
+		}
+		compiled, err := compiler.Compile(id)
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", p, err)
+		}
+
+		if r.schemas[family] == nil {
+			r.schemas[family] = make(map[string]*jsonschema.Schema)
+		}
+		r.schemas[family][version] = compiled
+		if versionNumber(version) > versionNumber(r.latest[family]) {
+			r.latest[family] = version
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// SchemaID returns the dataschema URI of a schema family version
+func SchemaID(family, version string) string {
+	return idPrefix + family + ":" + version
+}
+
+// Validate checks data against the schema for eventType. The version is
+// taken from dataSchema when set, otherwise the latest one is used.
+// It returns the version used, ErrNoSchema when the event type has no
+// schema, or a *ValidationError.
+func (r *Registry) Validate(eventType, dataSchema string, data []byte) (string, error) {
+	family, ok := eventFamilies[eventType]
+	if !ok {
+		return "", ErrNoSchema
+	}
+
+	version := r.latest[family]
+	if dataSchema != "" {
+		requested, ok := strings.CutPrefix(dataSchema, idPrefix+family+":")
+		if !ok {
+			return "", &ValidationError{EventType: eventType, Version: dataSchema, Errors: []string{"unknown dataschema " + dataSchema}}
+		}
+		version = requested
+	}
+
+	compiled, ok := r.schemas[family][version]
+	if !ok {
+		return version, &ValidationError{EventType: eventType, Version: version, Errors: []string{"unknown schema version " + version}}
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return version, &ValidationError{EventType: eventType, Version: version, Errors: []string{"invalid JSON: " + err.Error()}}
+	}
+
+	if err := compiled.Validate(doc); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if !errors.As(err, &validationErr) {
+			return version, err
+		}
+		return version, &ValidationError{EventType: eventType, Version: version, Errors: flatten(validationErr.BasicOutput())}
+	}
+
+	return version, nil
+}
+
+// flatten turns the basic output of a validation error into one line per cause
+func flatten(out *jsonschema.OutputUnit) []string {
+	var messages []string
+	for _, unit := range out.Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, location+": "+unit.Error.String())
+	}
+	if len(messages) == 0 && out.Error != nil {
+		messages = append(messages, out.Error.String())
+	}
+	return messages
+}
+
+// versionNumber parses "v<n>" versions, returning 0 when invalid
+func versionNumber(version string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
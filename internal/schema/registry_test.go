@@ -0,0 +1,60 @@
+package schema_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/PayeTonKawa-EPSI-2025/Orders-V2/internal/schema"
+)
+
+func TestValidate(t *testing.T) {
+	registry, err := schema.NewRegistry()
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+
+	version, err := registry.Validate("customer.created", "", []byte(`{"type":"customer.created","customer":{"ID":3}}`))
+	if err != nil {
+		t.Fatalf("expected valid event, got %v", err)
+	}
+	if version != "v1" {
+		t.Errorf("expected latest version v1, got %s", version)
+	}
+
+	_, err = registry.Validate("customer.created", "", []byte(`{"type":"customer.created","customer":{"ID":0}}`))
+	var validationErr *schema.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected validation error for customer ID 0, got %v", err)
+	}
+	if len(validationErr.Errors) == 0 {
+		t.Error("expected validation error details")
+	}
+
+	if _, err := registry.Validate("product.stock.rejected", "", []byte(`{"type":"product.stock.rejected","orderId":4,"productIds":[2]}`)); err != nil {
+		t.Errorf("expected valid stock event, got %v", err)
+	}
+	if _, err := registry.Validate("product.stock.reserved", "", []byte(`{"type":"product.stock.reserved"}`)); !errors.As(err, &validationErr) {
+		t.Errorf("expected validation error for a stock event without order, got %v", err)
+	}
+}
+
+func TestValidateSchemaVersion(t *testing.T) {
+	registry, err := schema.NewRegistry()
+	if err != nil {
+		t.Fatalf("failed to load registry: %v", err)
+	}
+
+	body := []byte(`{"type":"product.updated","product":{"ID":5,"stock":2}}`)
+
+	if _, err := registry.Validate("product.updated", schema.SchemaID("product-event", "v1"), body); err != nil {
+		t.Errorf("expected valid event for explicit v1, got %v", err)
+	}
+
+	if _, err := registry.Validate("product.updated", schema.SchemaID("product-event", "v9"), body); err == nil {
+		t.Error("expected error for unknown schema version")
+	}
+
+	if _, err := registry.Validate("order.created", "", body); !errors.Is(err, schema.ErrNoSchema) {
+		t.Errorf("expected ErrNoSchema, got %v", err)
+	}
+}